@@ -0,0 +1,186 @@
+package onedrivefs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"time"
+)
+
+// AsOf returns a read-only view of the FS as it was at the time t, built
+// from the version history of the files. Every file is served in its latest
+// version modified at or before t, and the files and the directories
+// created after t are hidden.
+//
+// The history of the renames and of the deletions isn't available, so the
+// files are served at their current paths and the deleted files are missing.
+func (f *FS) AsOf(t time.Time) fs.FS {
+	return &asOfFS{fs: f, t: t}
+}
+
+type asOfFS struct {
+	fs *FS
+	t  time.Time
+}
+
+var (
+	_ fs.FS        = &asOfFS{}
+	_ fs.ReadDirFS = &asOfFS{}
+	_ fs.StatFS    = &asOfFS{}
+)
+
+func (a *asOfFS) Open(name string) (fs.File, error) {
+	item, info, err := a.stat("open", name)
+	if err != nil {
+		return nil, err
+	}
+	if info.isDir {
+		return &asOfDir{fileInfo: info.fileInfo, fs: a, path: name}, nil
+	}
+	file := &openFile{
+		fileInfo: info.fileInfo,
+		fs:       a.fs,
+		client:   a.fs.opts.DownloadClient,
+		version:  info.version,
+	}
+	if info.version != "" {
+		// The version is addressed by its ID, so its content can't change.
+		downloadURL, err := a.fs.versionDownloadURL(a.fs.ctx, item.ID, info.version)
+		if err != nil {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+		}
+		file.downloadURL = downloadURL
+		return file, nil
+	}
+	// The current content is downloaded from the URL returned with the item
+	// the info was chosen from. Its content tag and hashes are verified by
+	// the reads, so a content changed since fails rather than being served.
+	if item.DownloadURL == "" {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: errors.New("the API didn't provide download URL")}
+	}
+	file.downloadURL = item.DownloadURL
+	file.checksum = newContentChecksum(info.sys.Hashes)
+	return file, nil
+}
+
+func (a *asOfFS) Stat(name string) (fs.FileInfo, error) {
+	_, info, err := a.stat("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return &info.fileInfo, nil
+}
+
+func (a *asOfFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if _, _, err := a.stat("readdir", name); err != nil {
+		return nil, err
+	}
+	return a.readDir(name)
+}
+
+// asOfInfo is the file info of an item at the time of the view, with the ID
+// of the version it comes from, empty for the current version.
+type asOfInfo struct {
+	fileInfo
+	version string
+}
+
+// stat returns the named item with its info at the time of the view.
+func (a *asOfFS) stat(op, name string) (*driveItem, *asOfInfo, error) {
+	item, err := a.fs.namedItem(op, name)
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := a.itemInfo(op, name, newFileInfo(item))
+	if err != nil {
+		return nil, nil, err
+	}
+	return item, info, nil
+}
+
+// itemInfo returns the info of the item at the time of the view, given its
+// current info.
+func (a *asOfFS) itemInfo(op, name string, info fileInfo) (*asOfInfo, error) {
+	if name != "." && info.sys.CreatedDateTime.After(a.t) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	if info.isDir || !info.modTime.After(a.t) {
+		return &asOfInfo{fileInfo: info}, nil
+	}
+	versions, err := a.fs.listVersions(a.fs.ctx, info.sys.ID)
+	if err != nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	var latest *Version
+	for i, v := range versions {
+		if !v.LastModifiedDateTime.After(a.t) && (latest == nil || v.LastModifiedDateTime.After(latest.LastModifiedDateTime)) {
+			latest = &versions[i]
+		}
+	}
+	if latest == nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	return &asOfInfo{fileInfo: versionFileInfo(info, *latest), version: latest.ID}, nil
+}
+
+// readDir returns the entries of the directory existing at the time of the
+// view.
+func (a *asOfFS) readDir(name string) ([]fs.DirEntry, error) {
+	entries, err := a.fs.ReadDir(name)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]fs.DirEntry, 0, len(entries))
+	for _, entry := range entries {
+		info, err := a.itemInfo("readdir", path.Join(name, entry.Name()), entry.(*dirEntry).fileInfo)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, &dirEntry{fileInfo: info.fileInfo})
+	}
+	return list, nil
+}
+
+// asOfDir is a directory opened in the view. Its entries are listed by the
+// first ReadDir.
+type asOfDir struct {
+	fileInfo
+	fs     *asOfFS
+	path   string
+	listed bool
+	items  []fs.DirEntry
+}
+
+var _ fs.ReadDirFile = &asOfDir{}
+
+func (d *asOfDir) Stat() (fs.FileInfo, error) { return &d.fileInfo, nil }
+
+func (d *asOfDir) ReadDir(count int) ([]fs.DirEntry, error) {
+	if !d.listed {
+		items, err := d.fs.readDir(d.path)
+		if err != nil {
+			return nil, err
+		}
+		d.listed, d.items = true, items
+	}
+	n := len(d.items)
+	if n == 0 && count > 0 {
+		return nil, io.EOF
+	}
+	if count > 0 && n > count {
+		n = count
+	}
+	list := d.items[:n:n]
+	d.items = d.items[n:]
+	return list, nil
+}
+
+func (d *asOfDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: fs.ErrInvalid}
+}
+
+func (d *asOfDir) Close() error { return nil }
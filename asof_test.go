@@ -0,0 +1,110 @@
+package onedrivefs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"go.dataddo.com/onedrivefs/onedrivefstest"
+)
+
+func TestFS_AsOf(t *testing.T) {
+	var now atomic.Int64
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	now.Store(start.Unix())
+	tick := func() { now.Add(60) }
+	fsys, srv := initFakeFileSystem(t, onedrivefstest.Options{
+		Now: func() time.Time { return time.Unix(now.Load(), 0) },
+	})
+	tick()
+	noErr(t, srv.WriteFile("README.md", []byte("second")))
+	noErr(t, srv.WriteFile("subdir1/subdir2/foo.csv", []byte("foo,bar\n3,4\n")))
+	tick()
+	noErr(t, srv.WriteFile("README.md", []byte("third")))
+	noErr(t, srv.WriteFile("subdir1/new.txt", []byte("new")))
+	noErr(t, srv.WriteFile("newdir/new.txt", []byte("new")))
+
+	t.Run("initial", func(t *testing.T) {
+		asOf := fsys.AsOf(start.Add(30 * time.Second))
+		noErr(t, fstest.TestFS(asOf, "README.md", "subdir1/subdir2/foo.json", "subdir1/subdir2/foo.csv", "subdir1/subdir2/foo-json"))
+		requireFileContent(t, asOf, "README.md", "This is a test dir for onedrivefs")
+		requireFileContent(t, asOf, "subdir1/subdir2/foo.csv", "foo,bar\n1,2\n")
+		info, err := fs.Stat(asOf, "README.md")
+		noErr(t, err)
+		assertEqual(t, true, info.ModTime().Equal(start), "modification time")
+		for _, name := range []string{"subdir1/new.txt", "newdir", "newdir/new.txt"} {
+			if _, err := fs.Stat(asOf, name); !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("%s: expected fs.ErrNotExist, got: %v", name, err)
+			}
+		}
+		entries, err := fs.ReadDir(asOf, ".")
+		noErr(t, err)
+		assertEqual(t, 2, len(entries), "root entries")
+	})
+
+	t.Run("between", func(t *testing.T) {
+		asOf := fsys.AsOf(start.Add(90 * time.Second))
+		noErr(t, fstest.TestFS(asOf, "README.md", "subdir1/subdir2/foo.csv"))
+		requireFileContent(t, asOf, "README.md", "second")
+		requireFileContent(t, asOf, "subdir1/subdir2/foo.csv", "foo,bar\n3,4\n")
+		if _, err := fs.Stat(asOf, "subdir1/new.txt"); !errors.Is(err, fs.ErrNotExist) {
+			t.Error("expected fs.ErrNotExist, got:", err)
+		}
+	})
+
+	t.Run("current", func(t *testing.T) {
+		asOf := fsys.AsOf(start.Add(time.Hour))
+		noErr(t, fstest.TestFS(asOf, "README.md", "subdir1/new.txt", "newdir/new.txt"))
+		requireFileContent(t, asOf, "README.md", "third")
+	})
+
+	t.Run("requests", func(t *testing.T) {
+		// The current content needs just the item, a version also its
+		// listing and its download URL.
+		for at, want := range map[time.Duration]int{time.Hour: 1, 90 * time.Second: 3} {
+			srv.ResetRequests()
+			f, err := fsys.AsOf(start.Add(at)).Open("README.md")
+			noErr(t, err)
+			noErr(t, f.Close())
+			assertEqual(t, want, apiRequests(srv), "API requests")
+		}
+	})
+
+	t.Run("changed after open", func(t *testing.T) {
+		f, err := fsys.AsOf(start.Add(time.Hour)).Open("README.md")
+		noErr(t, err)
+		t.Cleanup(func() { _ = f.Close() })
+		noErr(t, srv.WriteFile("README.md", []byte("THIRD")))
+		content, err := io.ReadAll(f)
+		if err == nil {
+			t.Fatalf("expected the changed content to fail, got: %q", content)
+		}
+	})
+
+	t.Run("versions error", func(t *testing.T) {
+		srv.InjectFault(onedrivefstest.Fault{
+			Match:      func(r *http.Request) bool { return strings.HasSuffix(r.URL.Path, "/versions") },
+			Count:      1,
+			StatusCode: http.StatusForbidden,
+			Code:       AccessDeniedErrorCode,
+		})
+		_, err := fsys.AsOf(start).Open("README.md")
+		var pathErr *fs.PathError
+		if !errors.As(err, &pathErr) || pathErr.Op != "open" || !errors.Is(err, fs.ErrPermission) {
+			t.Fatal("expected open *fs.PathError, got:", err)
+		}
+	})
+}
+
+func requireFileContent(t *testing.T, fsys fs.FS, name, want string) {
+	t.Helper()
+	content, err := fs.ReadFile(fsys, name)
+	noErr(t, err)
+	assertEqual(t, want, string(content), name)
+}
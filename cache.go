@@ -0,0 +1,162 @@
+package onedrivefs
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// CacheOptions configures the metadata cache of FS, see DriveOpts.Cache.
+type CacheOptions struct {
+	// TTL is how long the cached metadata are used without asking OneDrive.
+	// Expired metadata are revalidated by conditional requests. It defaults
+	// to 1 minute.
+	TTL time.Duration
+	// MaxEntries is the maximum number of the cached items and folder
+	// listings. The least recently used ones are evicted. It defaults to
+	// 10000.
+	MaxEntries int
+}
+
+const (
+	defaultCacheTTL        = time.Minute
+	defaultCacheMaxEntries = 10000
+	// downloadURLLifetime is how long the download URLs of the cached items
+	// are used. OneDrive keeps them valid for about an hour.
+	downloadURLLifetime = 30 * time.Minute
+)
+
+// metadataCache caches the items by their API URLs and the folder listings by
+// the IDs of the folders. It's safe for concurrent use.
+type metadataCache struct {
+	ttl        time.Duration
+	maxEntries int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+}
+
+// cacheEntry is a cached item or a folder listing. It's not modified once
+// cached.
+type cacheEntry struct {
+	key string
+	// item is the cached item or the listed folder.
+	item *driveItem
+	// children are the items of the listed folder, in the order of the
+	// listing.
+	children []*driveItem
+	// fetched is when the item or the listing was fetched, validated is when
+	// it was last confirmed to be up to date.
+	fetched   time.Time
+	validated time.Time
+}
+
+func newMetadataCache(opts CacheOptions) *metadataCache {
+	if opts.TTL <= 0 {
+		opts.TTL = defaultCacheTTL
+	}
+	if opts.MaxEntries <= 0 {
+		opts.MaxEntries = defaultCacheMaxEntries
+	}
+	return &metadataCache{
+		ttl:        opts.TTL,
+		maxEntries: opts.MaxEntries,
+		entries:    map[string]*list.Element{},
+		lru:        list.New(),
+	}
+}
+
+func itemCacheKey(apiURL string) string { return "item:" + apiURL }
+
+func childrenCacheKey(folderID string) string { return "children:" + folderID }
+
+// get returns the entry of the key, including the expired ones. It's safe to
+// call on a nil cache.
+func (c *metadataCache) get(key string) (*cacheEntry, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.lru.MoveToFront(elem)
+	return elem.Value.(*cacheEntry), true
+}
+
+// getFresh returns the entry of the key if it's not expired.
+func (c *metadataCache) getFresh(key string) (*cacheEntry, bool) {
+	entry, ok := c.get(key)
+	if !ok || !c.fresh(entry) {
+		return nil, false
+	}
+	return entry, true
+}
+
+func (c *metadataCache) fresh(entry *cacheEntry) bool {
+	return time.Since(entry.validated) < c.ttl
+}
+
+// revalidatable reports whether the expired entry can be revalidated by a
+// conditional request, rather than fetched again for new download URLs.
+func (c *metadataCache) revalidatable(entry *cacheEntry) bool {
+	return entry.item.ETag != "" && time.Since(entry.fetched) < downloadURLLifetime
+}
+
+// put caches the entry, evicting the least recently used ones above the
+// limit. It's safe to call on a nil cache.
+func (c *metadataCache) put(entry *cacheEntry) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[entry.key]; ok {
+		elem.Value = entry
+		c.lru.MoveToFront(elem)
+		return
+	}
+	c.entries[entry.key] = c.lru.PushFront(entry)
+	for c.lru.Len() > c.maxEntries {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+// renew caches a copy of the entry confirmed to be up to date.
+func (c *metadataCache) renew(entry *cacheEntry) {
+	renewed := *entry
+	renewed.validated = time.Now()
+	c.put(&renewed)
+}
+
+// removeFunc removes the entries for which remove returns true. It's safe to
+// call on a nil cache.
+func (c *metadataCache) removeFunc(remove func(*cacheEntry) bool) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, elem := range c.entries {
+		if remove(elem.Value.(*cacheEntry)) {
+			c.lru.Remove(elem)
+			delete(c.entries, key)
+		}
+	}
+}
+
+// purge removes all the entries. It's safe to call on a nil cache.
+func (c *metadataCache) purge() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	clear(c.entries)
+	c.lru.Init()
+}
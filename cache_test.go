@@ -0,0 +1,208 @@
+package onedrivefs
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"go.dataddo.com/onedrivefs/onedrivefstest"
+)
+
+func TestFS_cache(t *testing.T) {
+	srv, fsys := initCachedFileSystem(t, CacheOptions{TTL: time.Hour})
+
+	// Walking the tree caches the items, so reading the files needs only the
+	// downloads.
+	var files []string
+	noErr(t, fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			files = append(files, name)
+		}
+		return err
+	}))
+	srv.ResetRequests()
+	for _, name := range files {
+		_, err := fs.ReadFile(fsys, name)
+		noErr(t, err)
+		_, err = fsys.Stat(name)
+		noErr(t, err)
+	}
+	_, err := fsys.Stat("subdir1/missing")
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatal("expected fs.ErrNotExist, got:", err)
+	}
+	assertEqual(t, 0, apiRequests(srv), "API requests of cached items")
+
+	// The changes made by others are not visible until the cache expires.
+	noErr(t, srv.WriteFile("README.md", []byte("changed by others")))
+	info, err := fsys.Stat("README.md")
+	noErr(t, err)
+	assertEqual(t, int64(33), info.Size(), "README.md")
+
+	// The changes made through the FS invalidate just the changed items and
+	// their parent directories.
+	noErr(t, fsys.WriteFile("subdir1/new.txt", []byte("new"), 0o644))
+	srv.ResetRequests()
+	info, err = fsys.Stat("README.md")
+	noErr(t, err)
+	assertEqual(t, int64(33), info.Size(), "README.md")
+	_, err = fsys.Stat("subdir1/subdir2/foo.json")
+	noErr(t, err)
+	assertEqual(t, 0, apiRequests(srv), "API requests of unchanged items")
+	entries, err := fsys.ReadDir("subdir1")
+	noErr(t, err)
+	assertEqual(t, 2, len(entries), "subdir1")
+	info, err = fsys.Stat("subdir1/new.txt")
+	noErr(t, err)
+	assertEqual(t, int64(len("new")), info.Size(), "subdir1/new.txt")
+
+	noErr(t, fsys.Rename("subdir1/subdir2", "subdir3"))
+	_, err = fsys.Stat("subdir1/subdir2/foo.json")
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatal("expected fs.ErrNotExist, got:", err)
+	}
+	_, err = fsys.Stat("subdir3/foo.json")
+	noErr(t, err)
+	entries, err = fsys.ReadDir("subdir1")
+	noErr(t, err)
+	assertEqual(t, 1, len(entries), "subdir1")
+
+	noErr(t, fsys.RemoveAll("subdir3"))
+	_, err = fsys.Stat("subdir3/foo.json")
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatal("expected fs.ErrNotExist, got:", err)
+	}
+	entries, err = fsys.ReadDir(".")
+	noErr(t, err)
+	for _, entry := range entries {
+		if entry.Name() == "subdir3" {
+			t.Fatal("expected subdir3 to be removed from the listing")
+		}
+	}
+}
+
+func TestFS_cache_revalidate(t *testing.T) {
+	srv, fsys := initCachedFileSystem(t, CacheOptions{TTL: time.Nanosecond})
+	var conditional atomic.Int32
+	srv.InjectFault(onedrivefstest.Fault{Match: func(r *http.Request) bool {
+		if r.Header.Get("If-None-Match") != "" {
+			conditional.Add(1)
+		}
+		return false
+	}})
+
+	_, err := fsys.Stat("README.md")
+	noErr(t, err)
+	_, err = fsys.ReadDir("subdir1")
+	noErr(t, err)
+	assertEqual(t, int32(0), conditional.Load(), "conditional requests")
+
+	_, err = fsys.Stat("README.md")
+	noErr(t, err)
+	_, err = fsys.ReadDir("subdir1")
+	noErr(t, err)
+	// README.md, subdir1 opened by ReadDir and its listing.
+	assertEqual(t, int32(3), conditional.Load(), "conditional requests")
+
+	noErr(t, srv.WriteFile("README.md", []byte("changed")))
+	info, err := fsys.Stat("README.md")
+	noErr(t, err)
+	assertEqual(t, int64(len("changed")), info.Size(), "README.md")
+}
+
+func TestFS_cache_stale_changes(t *testing.T) {
+	srv, fsys := initCachedFileSystem(t, CacheOptions{TTL: time.Hour})
+
+	// Remove must not trust the cached empty folder.
+	noErr(t, srv.MkdirAll("dir"))
+	_, err := fsys.Stat("dir")
+	noErr(t, err)
+	noErr(t, srv.WriteFile("dir/precious.csv", []byte("precious")))
+	err = fsys.Remove("dir")
+	if !errors.Is(err, syscall.ENOTEMPTY) {
+		t.Fatal("expected syscall.ENOTEMPTY, got:", err)
+	}
+	_, err = srv.ReadFile("dir/precious.csv")
+	noErr(t, err)
+
+	// Rename must not replace a folder cached as a file.
+	_, err = fsys.Stat("README.md")
+	noErr(t, err)
+	noErr(t, srv.RemoveAll("README.md"))
+	noErr(t, srv.WriteFile("README.md/precious.csv", []byte("precious")))
+	err = fsys.Rename("subdir1/subdir2/foo.json", "README.md")
+	if !errors.Is(err, fs.ErrExist) {
+		t.Fatal("expected fs.ErrExist, got:", err)
+	}
+	_, err = srv.ReadFile("README.md/precious.csv")
+	noErr(t, err)
+
+	// Nor fail on a file removed since it was cached.
+	_, err = fsys.Stat("dir/precious.csv")
+	noErr(t, err)
+	noErr(t, srv.RemoveAll("dir/precious.csv"))
+	noErr(t, fsys.Rename("subdir1/subdir2/foo.json", "dir/precious.csv"))
+	got, err := srv.ReadFile("dir/precious.csv")
+	noErr(t, err)
+	assertEqual(t, `"is JSON"`, string(got), "dir/precious.csv")
+}
+
+func TestFS_cache_concurrent(t *testing.T) {
+	srv, fsys := initCachedFileSystem(t, CacheOptions{TTL: time.Hour, MaxEntries: 2})
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fsysCopy := fsys.Context(t.Context())
+			for _, name := range []string{"README.md", "subdir1", "subdir1/subdir2", "subdir1/subdir2/foo.json"} {
+				if _, err := fsysCopy.Stat(name); err != nil {
+					t.Error(err)
+				}
+			}
+			if i%2 == 0 {
+				if err := fsysCopy.WriteFile("subdir1/new.txt", nil, 0o644); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	// The least recently used items are evicted.
+	srv.ResetRequests()
+	_, err := fsys.Stat("README.md")
+	noErr(t, err)
+	_, err = fsys.Stat("subdir1")
+	noErr(t, err)
+	_, err = fsys.Stat("subdir1/subdir2")
+	noErr(t, err)
+	_, err = fsys.Stat("README.md")
+	noErr(t, err)
+	assertEqual(t, 4, apiRequests(srv), "API requests")
+}
+
+func initCachedFileSystem(t *testing.T, opts CacheOptions) (*onedrivefstest.Server, *FS) {
+	_, srv := initFakeFileSystem(t, onedrivefstest.Options{})
+	fsys, err := OpenFS(srv.Client(), DriveOpts{Endpoint: srv.Endpoint(), Cache: &opts})
+	noErr(t, err)
+	return srv, fsys
+}
+
+// apiRequests returns the number of the API requests served, the downloads
+// excluded.
+func apiRequests(srv *onedrivefstest.Server) int {
+	n := 0
+	for _, req := range srv.Requests() {
+		if strings.Contains(req, " /v1.0/") {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,60 @@
+package onedrivefs
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"hash"
+	"strings"
+
+	"go.dataddo.com/onedrivefs/quickxorhash"
+)
+
+// contentChecksum computes the hash of a file content read from the start,
+// to be compared with the hash reported by OneDrive.
+type contentChecksum struct {
+	algorithm string
+	hash      hash.Hash
+	want      []byte
+	encode    func([]byte) string
+}
+
+// newContentChecksum returns the checksum of the strongest of the hashes,
+// QuickXorHash being preferred as the one OneDrive computes for all the files
+// of business drives. It returns nil when there is no usable hash.
+func newContentChecksum(hashes Hashes) *contentChecksum {
+	encodeHex := func(sum []byte) string { return strings.ToUpper(hex.EncodeToString(sum)) }
+	switch {
+	case hashes.QuickXorHash != "":
+		want, err := base64.StdEncoding.DecodeString(hashes.QuickXorHash)
+		if err != nil || len(want) != quickxorhash.Size {
+			return nil
+		}
+		return &contentChecksum{"quickXorHash", quickxorhash.New(), want, base64.StdEncoding.EncodeToString}
+	case hashes.SHA256Hash != "":
+		want, err := hex.DecodeString(hashes.SHA256Hash)
+		if err != nil || len(want) != sha256.Size {
+			return nil
+		}
+		return &contentChecksum{"sha256Hash", sha256.New(), want, encodeHex}
+	case hashes.SHA1Hash != "":
+		want, err := hex.DecodeString(hashes.SHA1Hash)
+		if err != nil || len(want) != sha1.Size {
+			return nil
+		}
+		return &contentChecksum{"sha1Hash", sha1.New(), want, encodeHex}
+	}
+	return nil
+}
+
+// verify returns a ChecksumError when the hash of the content written so far
+// doesn't match the reported one.
+func (c *contentChecksum) verify() error {
+	got := c.hash.Sum(nil)
+	if bytes.Equal(c.want, got) {
+		return nil
+	}
+	return &ChecksumError{Algorithm: c.algorithm, Want: c.encode(c.want), Got: c.encode(got)}
+}
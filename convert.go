@@ -0,0 +1,129 @@
+package onedrivefs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// The formats of FS.OpenAs. Which of them are available depends on the type
+// of the file, e.g. FormatPDF converts the Office documents.
+//
+// OneDrive API docs: https://learn.microsoft.com/en-us/graph/api/driveitem-get-content-format
+const (
+	FormatPDF  = "pdf"
+	FormatHTML = "html"
+	FormatJPG  = "jpg"
+)
+
+// OpenAs opens the named file converted by OneDrive to the format, e.g.
+// FormatPDF. The converted content is streamed, so the file can't be seeked,
+// and its size is zero when OneDrive doesn't report it. The name of the file
+// has the extension of the format. When the file can't be converted to the
+// format, the error matches errors.ErrUnsupported.
+func (f *FS) OpenAs(name, format string) (fs.File, error) {
+	if format == "" {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	item, err := f.fileItem("open", name)
+	if err != nil {
+		return nil, err
+	}
+	downloadURL, err := f.contentLocation(f.ctx, f.itemIDURL(item.ID, "content")+"?format="+url.QueryEscape(format))
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	file, err := f.openStream(item, downloadURL)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	file.name = strings.TrimSuffix(item.Name, path.Ext(item.Name)) + "." + format
+	return file, nil
+}
+
+// openStream opens the content at the download URL as a file streaming a
+// derivative of the item, like its converted content. The metadata of the
+// file describe the original item, with the size and the type of the
+// derivative.
+func (f *FS) openStream(item *driveItem, downloadURL string) (*streamFile, error) {
+	resp, err := f.getStream(downloadURL)
+	if err != nil {
+		return nil, err
+	}
+	info := newFileInfo(item)
+	info.size = max(resp.ContentLength, 0)
+	info.sys.Size = info.size
+	info.sys.Hashes = Hashes{}
+	info.sys.MimeType = resp.Header.Get("Content-Type")
+	return &streamFile{fileInfo: info, body: resp.Body}, nil
+}
+
+// getStream starts the download of the content at the pre-authenticated
+// download URL.
+func (f *FS) getStream(downloadURL string) (*http.Response, error) {
+	req, err := http.NewRequest("GET", downloadURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := f.retry.do(f.ctx, f.opts.DownloadClient, req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer func() { _ = resp.Body.Close() }()
+		return nil, responseError(resp)
+	}
+	return resp, nil
+}
+
+// streamFile is a file opened by OpenAs or OpenThumbnail, streaming its
+// content.
+type streamFile struct {
+	fileInfo
+	body io.ReadCloser
+}
+
+func (f *streamFile) Stat() (fs.FileInfo, error) { return &f.fileInfo, nil }
+
+func (f *streamFile) Read(p []byte) (int, error) {
+	n, err := f.body.Read(p)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return n, &fs.PathError{Op: "read", Path: f.name, Err: err}
+	}
+	return n, err
+}
+
+func (f *streamFile) Close() error { return f.body.Close() }
+
+// contentLocation gets the pre-authenticated download URL the API redirects
+// the content request to. The redirect isn't followed, so the authenticated
+// client doesn't send the token to the download URL.
+func (f *FS) contentLocation(ctx context.Context, apiURL string) (string, error) {
+	req, err := f.newRequest("GET", apiURL, nil)
+	if err != nil {
+		return "", err
+	}
+	client := *f.client
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	resp, err := f.retry.do(ctx, &client, req)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode >= 400 {
+		return "", responseError(resp)
+	}
+	location, err := resp.Location()
+	if err != nil {
+		return "", fmt.Errorf("the content is not downloadable, because the API didn't redirect to download URL: %w", err)
+	}
+	return location.String(), nil
+}
@@ -0,0 +1,46 @@
+package onedrivefs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"syscall"
+	"testing"
+
+	"go.dataddo.com/onedrivefs/onedrivefstest"
+)
+
+func TestFS_OpenAs(t *testing.T) {
+	fsys, srv := initFakeFileSystem(t, onedrivefstest.Options{})
+	noErr(t, srv.WriteFile("docs/report.docx", []byte("report")))
+
+	f, err := fsys.OpenAs("docs/report.docx", FormatPDF)
+	noErr(t, err)
+	t.Cleanup(func() { _ = f.Close() })
+	content, err := io.ReadAll(f)
+	noErr(t, err)
+	assertEqual(t, "%PDF-1.7 fake\nreport", string(content), "content")
+	info, err := f.Stat()
+	noErr(t, err)
+	assertEqual(t, "report.pdf", info.Name(), "name")
+	assertEqual(t, int64(len(content)), info.Size(), "size")
+	assertEqual(t, "application/pdf", info.Sys().(*Item).MimeType, "MIME type")
+
+	_, err = fsys.OpenAs("README.md", FormatJPG)
+	var apiErr *OneDriveAPIError
+	if !errors.Is(err, errors.ErrUnsupported) || !errors.As(err, &apiErr) || apiErr.Code != NotSupportedErrorCode {
+		t.Error("expected notSupported error, got:", err)
+	}
+	_, err = fsys.OpenAs("missing.docx", FormatPDF)
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Error("expected fs.ErrNotExist, got:", err)
+	}
+	_, err = fsys.OpenAs("docs", FormatPDF)
+	if !errors.Is(err, syscall.EISDIR) {
+		t.Error("expected EISDIR, got:", err)
+	}
+	_, err = fsys.OpenAs("docs/report.docx", "")
+	if !errors.Is(err, fs.ErrInvalid) {
+		t.Error("expected fs.ErrInvalid, got:", err)
+	}
+}
@@ -0,0 +1,295 @@
+package onedrivefs
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ChangeType is the kind of a change reported by FS.Changes.
+type ChangeType int
+
+const (
+	// ChangeCreated means that the item was created.
+	ChangeCreated ChangeType = iota + 1
+	// ChangeModified means that the content, the name, the location or other
+	// metadata of the item changed.
+	ChangeModified
+	// ChangeDeleted means that the item was deleted.
+	ChangeDeleted
+)
+
+func (t ChangeType) String() string {
+	switch t {
+	case ChangeCreated:
+		return "created"
+	case ChangeModified:
+		return "modified"
+	case ChangeDeleted:
+		return "deleted"
+	}
+	return fmt.Sprintf("ChangeType(%d)", int(t))
+}
+
+// Change is a change of an item reported by FS.Changes.
+type Change struct {
+	Type ChangeType
+	// Path is the slash separated path of the item, the same as accepted by
+	// Open. A moved item is reported with its new path only. Path is empty
+	// for deleted items whose location can't be resolved anymore.
+	Path  string
+	IsDir bool
+	// Item is the metadata of the item. Deleted items carry only a part of
+	// it, at least the ID.
+	Item *Item
+}
+
+// Changes returns an iterator over the changes of the drive since the state
+// identified by the token. With an empty token, all the items are reported
+// as created. When the iteration completes, the iterator provides the token
+// for the next call.
+//
+// OneDrive doesn't tell created items from modified ones, so the items
+// created after the state of the token are reported as created and the
+// others as modified. Items created within the same second as the previous
+// call may be reported as modified.
+//
+// OneDrive API docs: https://learn.microsoft.com/en-us/graph/api/driveitem-delta
+func (f *FS) Changes(ctx context.Context, token string) *ChangeIterator {
+	if ctx == nil {
+		ctx = f.ctx
+	}
+	it := &ChangeIterator{
+		fs:    f,
+		ctx:   ctx,
+		paths: newPathResolver(ctx, f),
+	}
+	it.token, it.err = parseChangeToken(token)
+	return it
+}
+
+// LatestChangeToken returns a token of the current state of the drive, so
+// Changes reports only the changes made after the call.
+func (f *FS) LatestChangeToken(ctx context.Context) (string, error) {
+	if ctx == nil {
+		ctx = f.ctx
+	}
+	req, err := f.newRequest("GET", f.deltaURL(), nil)
+	if err != nil {
+		return "", err
+	}
+	req.URL.RawQuery = url.Values{"token": {"latest"}}.Encode()
+	var page *driveItemsResponse
+	header, err := f.doRequestHeader(ctx, req, &page)
+	if err != nil {
+		return "", deltaError(err)
+	}
+	if page.DeltaLink == "" {
+		return "", errors.New("the API didn't provide delta link")
+	}
+	return changeToken{DeltaLink: page.DeltaLink, Since: responseDate(header)}.String(), nil
+}
+
+// ChangeIterator iterates over the changes returned by FS.Changes. It's used
+// like bufio.Scanner:
+//
+//	changes := fsys.Changes(ctx, token)
+//	for changes.Next() {
+//		change := changes.Change()
+//		...
+//	}
+//	if err := changes.Err(); err != nil {
+//		...
+//	}
+//	token = changes.Token()
+type ChangeIterator struct {
+	fs       *FS
+	ctx      context.Context
+	token    changeToken
+	nextLink string
+	started  bool
+	// syncTime is the time of the first response, the state the next token
+	// continues from.
+	syncTime time.Time
+	paths    *pathResolver
+	pending  []Change
+	change   Change
+	newToken string
+	err      error
+}
+
+// Next advances the iterator to the next change, which is then available
+// through Change. It returns false when there are no more changes or when
+// an error occurred.
+func (it *ChangeIterator) Next() bool {
+	for len(it.pending) == 0 {
+		if it.err != nil || it.newToken != "" {
+			return false
+		}
+		it.err = it.fetchPage()
+	}
+	it.change, it.pending = it.pending[0], it.pending[1:]
+	return true
+}
+
+// Change returns the current change.
+func (it *ChangeIterator) Change() Change {
+	return it.change
+}
+
+// Err returns the error that stopped the iteration, if any. It's a
+// *ResyncRequiredError when the token is no longer valid.
+func (it *ChangeIterator) Err() error {
+	return it.err
+}
+
+// Token returns the token to pass to the next FS.Changes call. It's empty
+// until Next returns false without an error.
+func (it *ChangeIterator) Token() string {
+	return it.newToken
+}
+
+// fetchPage fetches the next page of the changes.
+func (it *ChangeIterator) fetchPage() error {
+	apiURL := it.nextLink
+	if !it.started {
+		apiURL = it.token.DeltaLink
+		if apiURL == "" {
+			apiURL = it.fs.deltaURL()
+		}
+		if err := it.paths.resolveRoot(); err != nil {
+			return err
+		}
+	}
+	req, err := it.fs.newRequest("GET", apiURL, nil)
+	if err != nil {
+		return err
+	}
+	var page *driveItemsResponse
+	header, err := it.fs.doRequestHeader(it.ctx, req, &page)
+	if err != nil {
+		return deltaError(err)
+	}
+	if !it.started {
+		it.started = true
+		it.syncTime = responseDate(header)
+	}
+
+	var changes []Change
+	for _, item := range page.DriveItems {
+		if item.Root != nil {
+			// It only makes the root known for the paths of its children.
+			_, _ = it.paths.drivePath(item)
+			continue
+		}
+		change, err := it.newChange(item)
+		if err != nil {
+			return err
+		}
+		if change.Path != "" {
+			relPath, ok := it.paths.relPath(change.Path)
+			if !ok {
+				continue
+			}
+			change.Path = relPath
+		}
+		changes = append(changes, change)
+	}
+	switch {
+	case page.NextLink != "":
+		it.nextLink = page.NextLink
+	case page.DeltaLink != "":
+		it.newToken = changeToken{DeltaLink: page.DeltaLink, Since: it.syncTime}.String()
+	default:
+		return errors.New("the API provided neither next nor delta link")
+	}
+	it.pending = changes
+	return nil
+}
+
+func (it *ChangeIterator) newChange(item *driveItem) (Change, error) {
+	change := Change{
+		IsDir: item.Folder != nil,
+		Item:  item.metadata(),
+	}
+	switch {
+	case item.Deleted != nil:
+		change.Type = ChangeDeleted
+	case it.token.DeltaLink == "" || time.Time(item.CreatedDateTime).After(it.token.Since):
+		change.Type = ChangeCreated
+	default:
+		change.Type = ChangeModified
+	}
+	if item.Deleted != nil && item.Name == "" {
+		return change, nil
+	}
+	drivePath, err := it.paths.drivePath(item)
+	if err != nil {
+		if item.Deleted != nil && errors.Is(err, fs.ErrNotExist) {
+			return change, nil
+		}
+		return Change{}, err
+	}
+	change.Path = drivePath
+	return change, nil
+}
+
+// deltaURL returns the API URL of the delta query. Only OneDrive personal
+// supports delta queries of folders, so the changes of an FS returned by Sub
+// are filtered from the changes of the whole drive.
+func (f *FS) deltaURL() string {
+	return f.opts.Drive.apiPath() + "/root/delta"
+}
+
+// deltaError turns the errors of expired delta tokens to ResyncRequiredError.
+func deltaError(err error) error {
+	// OneDrive uses more codes starting with "resync", like
+	// resyncChangesApplyDifferences, all asking for a full resync.
+	if odErr := (&OneDriveAPIError{}); errors.As(err, &odErr) && strings.HasPrefix(odErr.Code, "resync") {
+		return &ResyncRequiredError{Err: odErr}
+	}
+	return err
+}
+
+// responseDate returns the time of the Date header, falling back to the
+// current time.
+func responseDate(header http.Header) time.Time {
+	if t, err := http.ParseTime(header.Get("Date")); err == nil {
+		return t
+	}
+	return time.Now()
+}
+
+// changeToken is the state a delta query continues from. It's passed around
+// encoded by String.
+type changeToken struct {
+	DeltaLink string    `json:"deltaLink"`
+	Since     time.Time `json:"since"`
+}
+
+func (t changeToken) String() string {
+	data, _ := json.Marshal(t)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func parseChangeToken(s string) (changeToken, error) {
+	var token changeToken
+	if s == "" {
+		return token, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err == nil {
+		err = json.Unmarshal(data, &token)
+	}
+	if err != nil || token.DeltaLink == "" {
+		return token, errors.New("invalid change token")
+	}
+	return token, nil
+}
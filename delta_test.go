@@ -0,0 +1,113 @@
+package onedrivefs
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.dataddo.com/onedrivefs/onedrivefstest"
+)
+
+func TestFS_Changes(t *testing.T) {
+	var now atomic.Int64
+	now.Store(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).Unix())
+	tick := func() { now.Add(60) }
+	fsys, srv := initFakeFileSystem(t, onedrivefstest.Options{
+		PageSize: 2,
+		Now:      func() time.Time { return time.Unix(now.Load(), 0) },
+	})
+	ctx := context.Background()
+
+	token := requireChanges(t, fsys, "", map[string]ChangeType{
+		"README.md":                ChangeCreated,
+		"subdir1":                  ChangeCreated,
+		"subdir1/subdir2":          ChangeCreated,
+		"subdir1/subdir2/foo-json": ChangeCreated,
+		"subdir1/subdir2/foo.csv":  ChangeCreated,
+		"subdir1/subdir2/foo.json": ChangeCreated,
+	})
+
+	tick()
+	noErr(t, fsys.WriteFile("subdir1/new.txt", []byte("new"), 0o644))
+	noErr(t, fsys.WriteFile("README.md", []byte("replaced"), 0o644))
+	noErr(t, fsys.Rename("subdir1/subdir2/foo.csv", "subdir1/foo.csv"))
+	noErr(t, fsys.Remove("subdir1/subdir2/foo-json"))
+	token = requireChanges(t, fsys, token, map[string]ChangeType{
+		"README.md":                ChangeModified,
+		"subdir1":                  ChangeModified,
+		"subdir1/new.txt":          ChangeCreated,
+		"subdir1/foo.csv":          ChangeModified,
+		"subdir1/subdir2":          ChangeModified,
+		"subdir1/subdir2/foo-json": ChangeDeleted,
+	})
+	token = requireChanges(t, fsys, token, map[string]ChangeType{})
+
+	// The parent of the changed folder is looked up by ID.
+	latest, err := fsys.LatestChangeToken(ctx)
+	noErr(t, err)
+	tick()
+	noErr(t, srv.WriteFile("subdir1/subdir2/late.txt", []byte("late")))
+	requireChanges(t, fsys, latest, map[string]ChangeType{
+		"subdir1/subdir2":          ChangeModified,
+		"subdir1/subdir2/late.txt": ChangeCreated,
+	})
+
+	srv.ExpireDeltaTokens()
+	changes := fsys.Changes(ctx, token)
+	if changes.Next() {
+		t.Fatal("unexpected change:", changes.Change())
+	}
+	var resyncErr *ResyncRequiredError
+	if !errors.As(changes.Err(), &resyncErr) || resyncErr.Err.Code != ResyncRequiredErrorCode {
+		t.Fatal("expected ResyncRequiredError, got:", changes.Err())
+	}
+	assertEqual(t, "", changes.Token(), "token")
+
+	if err := fsys.Changes(ctx, "invalid").Err(); err == nil {
+		t.Fatal("expected error of invalid token")
+	}
+}
+
+// requireChanges iterates over the changes since the token and compares them
+// by path with want. It returns the next token.
+func requireChanges(t *testing.T, fsys *FS, token string, want map[string]ChangeType) string {
+	t.Helper()
+	changes := fsys.Changes(context.Background(), token)
+	got := map[string]ChangeType{}
+	for changes.Next() {
+		change := changes.Change()
+		got[change.Path] = change.Type
+		if change.Item == nil || change.Item.ID == "" {
+			t.Errorf("change of %q: missing item ID", change.Path)
+		}
+	}
+	noErr(t, changes.Err())
+	if !maps.Equal(want, got) {
+		t.Fatalf("want changes %v, got %v", want, got)
+	}
+	if changes.Token() == "" {
+		t.Fatal("missing next token")
+	}
+	return changes.Token()
+}
+
+func TestFS_Changes_sub(t *testing.T) {
+	fsys, srv := initFakeFileSystem(t, onedrivefstest.Options{})
+	sub, err := fsys.Sub("subdir1")
+	noErr(t, err)
+	token := requireChanges(t, sub.(*FS), "", map[string]ChangeType{
+		"subdir2":          ChangeCreated,
+		"subdir2/foo-json": ChangeCreated,
+		"subdir2/foo.csv":  ChangeCreated,
+		"subdir2/foo.json": ChangeCreated,
+	})
+	noErr(t, srv.WriteFile("README.md", []byte("changed")))
+	noErr(t, srv.RemoveAll("subdir1/subdir2/foo.csv"))
+	requireChanges(t, sub.(*FS), token, map[string]ChangeType{
+		"subdir2":         ChangeModified,
+		"subdir2/foo.csv": ChangeDeleted,
+	})
+}
@@ -0,0 +1,204 @@
+package onedrivefs
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	defaultDownloadResumes   = 3
+	defaultDownloadChunkSize = 8 << 20
+)
+
+// DefaultDownloadClient is used for the content downloads and the upload
+// sessions when DriveOpts.DownloadClient is nil. It takes the proxy from the
+// environment, keeps the connections to the storage hosts alive for reuse and
+// gives up on servers that stop responding, while not limiting the duration
+// of the transfers.
+var DefaultDownloadClient = &http.Client{Transport: newDownloadTransport()}
+
+func newDownloadTransport() *http.Transport {
+	return &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   16,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: time.Minute,
+		ExpectContinueTimeout: time.Second,
+	}
+}
+
+// DownloadTo downloads the content of the named file to w. The byte ranges of
+// DriveOpts.DownloadChunkSize are downloaded by DriveOpts.DownloadConcurrency
+// parallel requests and written as they arrive, so w must support concurrent
+// WriteAt calls, like *os.File does. The ranges arrive out of order, so
+// unlike the sequential reads of the opened files, the downloaded content
+// isn't verified against the hash reported by OneDrive.
+func (f *FS) DownloadTo(ctx context.Context, name string, w io.WriterAt) error {
+	file, err := f.Context(ctx).Open(name)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = file.Close() }()
+	of, ok := file.(*openFile)
+	if !ok {
+		return &fs.PathError{Op: "download", Path: name, Err: syscall.EISDIR}
+	}
+	if err := of.downloadTo(w); err != nil {
+		return &fs.PathError{Op: "download", Path: name, Err: err}
+	}
+	return nil
+}
+
+func (f *openFile) downloadTo(w io.WriterAt) error {
+	ctx, cancel := context.WithCancel(f.fs.ctx)
+	defer cancel()
+	chunkSize := f.fs.opts.DownloadChunkSize
+	workers := max(f.fs.opts.DownloadConcurrency, 1)
+	offsets := make(chan int64)
+	// Every worker sends at most one error, the first one sent is the cause of
+	// the cancellation of the others.
+	errs := make(chan error, workers)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			buf := make([]byte, min(chunkSize, f.size))
+			for off := range offsets {
+				p := buf[:min(chunkSize, f.size-off)]
+				_, err := f.readRange(ctx, p, off)
+				if err == nil {
+					_, err = w.WriteAt(p, off)
+				}
+				if err != nil {
+					errs <- err
+					cancel()
+					return
+				}
+			}
+		}()
+	}
+feed:
+	for off := int64(0); off < f.size; off += chunkSize {
+		select {
+		case offsets <- off:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(offsets)
+	wg.Wait()
+	close(errs)
+	if err := <-errs; err != nil {
+		return err
+	}
+	// The context may be canceled after the last range was handed out.
+	return ctx.Err()
+}
+
+// prefetcher reads the content of a file sequentially, while downloading the
+// following byte ranges in parallel. At most DriveOpts.DownloadConcurrency
+// ranges are held in memory, including the one being read.
+type prefetcher struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	// chunks are the ranges being downloaded, in the order of their offsets.
+	chunks chan *chunk
+	// window has a slot taken for every range in memory.
+	window chan struct{}
+
+	cur *chunk
+	pos int
+	err error
+}
+
+type chunk struct {
+	data []byte
+	err  error
+	// done is closed when the range is downloaded.
+	done chan struct{}
+}
+
+// newPrefetcher starts downloading the content of the file from the offset.
+func newPrefetcher(f *openFile, offset int64) *prefetcher {
+	ctx, cancel := context.WithCancel(f.fs.ctx)
+	concurrency := f.fs.opts.DownloadConcurrency
+	p := &prefetcher{
+		ctx:    ctx,
+		cancel: cancel,
+		chunks: make(chan *chunk, concurrency),
+		window: make(chan struct{}, concurrency),
+	}
+	chunkSize := f.fs.opts.DownloadChunkSize
+	go func() {
+		defer close(p.chunks)
+		for off := offset; off < f.size; off += chunkSize {
+			select {
+			case p.window <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			c := &chunk{
+				data: make([]byte, min(chunkSize, f.size-off)),
+				done: make(chan struct{}),
+			}
+			go func() {
+				defer close(c.done)
+				_, c.err = f.readRange(ctx, c.data, off)
+			}()
+			// The window keeps the number of the queued ranges within the
+			// capacity of the channel.
+			p.chunks <- c
+		}
+	}()
+	return p
+}
+
+func (p *prefetcher) Read(b []byte) (int, error) {
+	if p.err != nil {
+		return 0, p.err
+	}
+	if p.cur == nil || p.pos == len(p.cur.data) {
+		if p.cur != nil {
+			// The range was read, its slot is released for the next one.
+			p.cur = nil
+			<-p.window
+		}
+		c, ok := <-p.chunks
+		if !ok {
+			// The ranges stop early only when the context is done.
+			p.err = p.ctx.Err()
+			if p.err == nil {
+				p.err = io.EOF
+			}
+			return 0, p.err
+		}
+		<-c.done
+		if c.err != nil {
+			p.err = c.err
+			return 0, p.err
+		}
+		p.cur, p.pos = c, 0
+	}
+	n := copy(b, p.cur.data[p.pos:])
+	p.pos += n
+	return n, nil
+}
+
+// Close stops the downloads.
+func (p *prefetcher) Close() {
+	p.cancel()
+}
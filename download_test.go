@@ -0,0 +1,93 @@
+package onedrivefs
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"go.dataddo.com/onedrivefs/onedrivefstest"
+)
+
+func TestFS_DownloadTo(t *testing.T) {
+	fsys, srv, content := initParallelFileSystem(t)
+
+	path := filepath.Join(t.TempDir(), "big.bin")
+	file, err := os.Create(path)
+	noErr(t, err)
+	t.Cleanup(func() { _ = file.Close() })
+	srv.ResetRequests()
+	// The interrupted ranges are resumed.
+	srv.InjectFault(onedrivefstest.Fault{
+		Match:    func(r *http.Request) bool { return strings.HasPrefix(r.URL.Path, "/download/") },
+		Count:    2,
+		Truncate: 1 << 10,
+	})
+	noErr(t, fsys.DownloadTo(t.Context(), "big.bin", file))
+	got, err := os.ReadFile(path)
+	noErr(t, err)
+	assertEqual(t, true, bytes.Equal(content, got), "big.bin")
+	assertEqual(t, 12, downloads(srv), "downloads")
+
+	noErr(t, fsys.DownloadTo(t.Context(), "README.md", file))
+	err = fsys.DownloadTo(t.Context(), "subdir1", file)
+	if !errors.Is(err, syscall.EISDIR) {
+		t.Fatal("expected EISDIR, got:", err)
+	}
+	err = fsys.DownloadTo(t.Context(), "missing", file)
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatal("expected fs.ErrNotExist, got:", err)
+	}
+}
+
+func TestFS_Open_parallel(t *testing.T) {
+	fsys, srv, content := initParallelFileSystem(t)
+	f, err := fsys.Open("big.bin")
+	noErr(t, err)
+	t.Cleanup(func() { _ = f.Close() })
+
+	srv.ResetRequests()
+	first := make([]byte, 1)
+	_, err = io.ReadFull(f, first)
+	noErr(t, err)
+	if n := downloads(srv); n > fsys.opts.DownloadConcurrency {
+		t.Errorf("%d ranges downloaded before reading the first one", n)
+	}
+	rest, err := io.ReadAll(f)
+	noErr(t, err)
+	assertEqual(t, true, bytes.Equal(content, append(first, rest...)), "big.bin")
+	assertEqual(t, 10, downloads(srv), "downloads")
+
+	// Seeking restarts the downloads from the new offset.
+	_, err = f.(io.Seeker).Seek(55<<10, io.SeekStart)
+	noErr(t, err)
+	rest, err = io.ReadAll(f)
+	noErr(t, err)
+	assertEqual(t, true, bytes.Equal(content[55<<10:], rest), "big.bin")
+}
+
+// initParallelFileSystem returns an FS downloading 10 KiB ranges of files by
+// 4 parallel requests, and the content of its 100 KiB file big.bin.
+func initParallelFileSystem(t *testing.T) (*FS, *onedrivefstest.Server, []byte) {
+	_, srv := initFakeFileSystem(t, onedrivefstest.Options{})
+	content := make([]byte, 100<<10)
+	for i := range content {
+		content[i] = byte(i * 7 / 3)
+	}
+	noErr(t, srv.WriteFile("big.bin", content))
+	fsys, err := OpenFS(srv.Client(), DriveOpts{
+		Endpoint:            srv.Endpoint(),
+		Retry:               &RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond},
+		DownloadConcurrency: 4,
+		DownloadChunkSize:   10 << 10,
+	})
+	noErr(t, err)
+	return fsys, srv, content
+}
@@ -0,0 +1,64 @@
+package onedrivefs
+
+import (
+	"net/url"
+	"strings"
+)
+
+// Drive identifies the drive an FS operates on. The zero value is the OneDrive
+// of the signed-in user, same as MyDrive.
+//
+// OneDrive API docs: https://learn.microsoft.com/en-us/graph/api/drive-get
+type Drive struct {
+	// path is the API path of the drive, e.g. "users/{id}/drive".
+	path string
+}
+
+// MyDrive returns the OneDrive of the signed-in user. It requires a delegated
+// token.
+func MyDrive() Drive { return Drive{} }
+
+// DriveByID returns the drive of the ID.
+func DriveByID(driveID string) Drive {
+	return Drive{path: "drives/" + url.PathEscape(driveID)}
+}
+
+// UserDrive returns the OneDrive of the user identified by ID or user
+// principal name. With an app-only token, it opens the drive of any user of
+// the tenant.
+func UserDrive(userID string) Drive {
+	return Drive{path: "users/" + url.PathEscape(userID) + "/drive"}
+}
+
+// GroupDrive returns the document library of a Microsoft 365 group, e.g. the
+// files of a Teams team.
+func GroupDrive(groupID string) Drive {
+	return Drive{path: "groups/" + url.PathEscape(groupID) + "/drive"}
+}
+
+// SiteDrive returns the default document library of a SharePoint site. The
+// site ID has the form "{hostname},{site-collection-id},{web-id}".
+func SiteDrive(siteID string) Drive {
+	return Drive{path: "sites/" + escapeSiteID(siteID) + "/drive"}
+}
+
+// SiteLibrary returns a document library of a SharePoint site. The library is
+// identified by its drive ID.
+func SiteLibrary(siteID, libraryID string) Drive {
+	return Drive{path: "sites/" + escapeSiteID(siteID) + "/drives/" + url.PathEscape(libraryID)}
+}
+
+// String returns the API path of the drive, e.g. "users/{id}/drive".
+func (d Drive) String() string { return d.apiPath() }
+
+func (d Drive) apiPath() string {
+	if d.path == "" {
+		return "me/drive"
+	}
+	return d.path
+}
+
+// escapeSiteID escapes the site ID, keeping the commas separating its parts.
+func escapeSiteID(siteID string) string {
+	return strings.ReplaceAll(url.PathEscape(siteID), "%2C", ",")
+}
@@ -1,6 +1,10 @@
 package onedrivefs
 
-import "net/http"
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+)
 
 // This is a list of some error codes returned by OneDrive API.
 const (
@@ -42,6 +46,7 @@ type OneDriveAPIError struct {
 	Message          string      `json:"message"`
 	LocalizedMessage string      `json:"localizedMessage"`
 	InnerError       *InnerError `json:"innerError"`
+	StatusCode       int         `json:"-"`
 	ResponseHeader   http.Header `json:"-"`
 }
 
@@ -52,6 +57,97 @@ func (e *OneDriveAPIError) Error() string {
 	return e.Code + " - " + e.Message
 }
 
+// Is makes the error match the corresponding errors of the fs package, so e.g.
+// errors.Is(err, fs.ErrNotExist) reports whether the item was not found. The
+// notSupported errors match errors.ErrUnsupported.
+func (e *OneDriveAPIError) Is(target error) bool {
+	switch target {
+	case errors.ErrUnsupported:
+		return e.Code == NotSupportedErrorCode
+	case fs.ErrNotExist:
+		return e.Code == ItemNotFoundErrorCode
+	case fs.ErrExist:
+		return e.Code == NameAlreadyExistsErrorCode
+	case fs.ErrPermission:
+		return e.Code == AccessDeniedErrorCode
+	}
+	return false
+}
+
+// HTTPError is an unexpected HTTP response which doesn't describe the error by
+// OneDrive API error, e.g. a failed download.
+type HTTPError struct {
+	StatusCode int
+	Status     string
+	Header     http.Header
+}
+
+func (e *HTTPError) Error() string {
+	return "unexpected response: " + e.Status
+}
+
+// Is makes the error match the corresponding errors of the fs package, like
+// OneDriveAPIError.Is.
+func (e *HTTPError) Is(target error) bool {
+	switch target {
+	case fs.ErrNotExist:
+		return e.StatusCode == http.StatusNotFound || e.StatusCode == http.StatusGone
+	case fs.ErrPermission:
+		return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
+	}
+	return false
+}
+
+// ErrContentChanged is returned by the reads of a file whose content changed
+// on OneDrive since the file was opened, so the read data would mix the
+// versions. The file must be opened again.
+var ErrContentChanged = errors.New("file content changed since opened")
+
+// ChecksumError is returned by the read of a file reaching its end, when the
+// hash of the read content doesn't match the hash reported by OneDrive, so
+// the content was corrupted or changed during the download. The hashes are
+// encoded like in Hashes.
+type ChecksumError struct {
+	// Algorithm is the name of the compared hash, e.g. "quickXorHash".
+	Algorithm string
+	Want      string
+	Got       string
+}
+
+func (e *ChecksumError) Error() string {
+	return "checksum mismatch: " + e.Algorithm + " of the content is " + e.Got + ", expected " + e.Want
+}
+
+// ResyncRequiredError is returned when OneDrive can't continue tracking the
+// changes from a token passed to FS.Changes anymore. The caller must do a
+// full resync: start over with an empty token, which reports all the items
+// as created.
+type ResyncRequiredError struct {
+	Err *OneDriveAPIError
+}
+
+func (e *ResyncRequiredError) Error() string {
+	return "resync required: " + e.Err.Error()
+}
+
+func (e *ResyncRequiredError) Unwrap() error {
+	return e.Err
+}
+
+// errorStatusCode returns the HTTP status of the response the error comes
+// from, zero when unknown.
+func errorStatusCode(err error) int {
+	var apiErr *OneDriveAPIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode
+	}
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.StatusCode
+	}
+	return 0
+}
+
 type InnerError struct {
 	Date            string `json:"date"`
 	RequestID       string `json:"request-id"`
@@ -18,12 +18,13 @@ func Example() {
 	}
 	client := oauth2.NewClient(ctx, config.TokenSource(ctx, tok))
 	// Create a new OneDrive client.
-	fs, _ := OpenFS(client, DriveOpts{DriveID: ""})
+	fs, _ := OpenFS(client, DriveOpts{Drive: MyDrive()})
 	f, err := fs.Context(ctx).Open("mydir/foo.json")
 	if err != nil {
 		var odErr *OneDriveAPIError
 		if errors.As(err, &odErr) && odErr.Code == ActivityLimitReachedErrorCode {
-			// Handle rate limit error.
+			// Handle rate limit error, which persisted even after the retries
+			// done according to DriveOpts.Retry.
 			odErr.ResponseHeader.Get("Retry-After")
 		}
 	}
@@ -1,9 +1,14 @@
 package onedrivefs
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"io"
 	"io/fs"
+	"net/http"
 	"slices"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -11,24 +16,318 @@ import (
 
 type openFile struct {
 	fileInfo
-	data io.ReadCloser
+	fs     *FS
+	client *http.Client
+
+	// mu guards downloadURL, which is refreshed by the concurrent ReadAt
+	// calls when it expires.
+	mu          sync.Mutex
+	downloadURL string
+
+	// data is the content stream positioned at offset, nil when there is
+	// no stream open.
+	data   io.ReadCloser
+	offset int64
+	// resumes counts the resumes of the stream since it last read anything.
+	resumes int
+	// prefetch replaces the stream when the content is downloaded in
+	// parallel, see DriveOpts.DownloadConcurrency.
+	prefetch *prefetcher
+	// checksum is computed from the content read sequentially from the start
+	// up to the hashed offset, and verified at the end. It's nil when
+	// OneDrive reported no hash.
+	checksum *contentChecksum
+	hashed   int64
+	// version is the ID of the opened version, empty for the current one.
+	version string
+}
+
+var (
+	_ fs.File     = &openFile{}
+	_ io.Seeker   = &openFile{}
+	_ io.ReaderAt = &openFile{}
+)
+
+func (f *openFile) Stat() (fs.FileInfo, error) { return &f.fileInfo, nil }
+
+func (f *openFile) Read(p []byte) (int, error) {
+	offset := f.offset
+	n, err := f.read(p)
+	// Reads following a seek away from the hashed offset aren't hashed, until
+	// the file is seeked back.
+	if n > 0 && f.checksum != nil && offset == f.hashed {
+		_, _ = f.checksum.hash.Write(p[:n])
+		f.hashed += int64(n)
+		if f.hashed == f.size {
+			if err := f.checksum.verify(); err != nil {
+				return n, &fs.PathError{Op: "read", Path: f.name, Err: err}
+			}
+		}
+	}
+	return n, err
+}
+
+func (f *openFile) read(p []byte) (int, error) {
+	if f.offset >= f.size {
+		return 0, io.EOF
+	}
+	if f.prefetch == nil && f.data == nil && f.fs.opts.DownloadConcurrency > 1 &&
+		f.size-f.offset > f.fs.opts.DownloadChunkSize {
+		f.prefetch = newPrefetcher(f, f.offset)
+	}
+	if f.prefetch != nil {
+		n, err := f.prefetch.Read(p)
+		f.offset += int64(n)
+		if err != nil && err != io.EOF {
+			return n, &fs.PathError{Op: "read", Path: f.name, Err: err}
+		}
+		return n, err
+	}
+	for {
+		if f.data == nil {
+			data, err := f.openRange(f.fs.ctx, f.offset, -1)
+			if err != nil {
+				return 0, &fs.PathError{Op: "read", Path: f.name, Err: err}
+			}
+			f.data = data
+		}
+		n, err := f.data.Read(p)
+		f.offset += int64(n)
+		if n > 0 {
+			f.resumes = 0
+		}
+		if err == nil || (err == io.EOF && f.offset >= f.size) {
+			return n, err
+		}
+		// The download was interrupted, it's resumed from the current offset.
+		_ = f.data.Close()
+		f.data = nil
+		if err := f.waitResume(f.fs.ctx, f.resumes, err); err != nil {
+			return n, &fs.PathError{Op: "read", Path: f.name, Err: err}
+		}
+		f.resumes++
+		if n > 0 {
+			return n, nil
+		}
+	}
+}
+
+func (f *openFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += f.size
+	default:
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: fs.ErrInvalid}
+	}
+	if offset < 0 {
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: fs.ErrInvalid}
+	}
+	if offset != f.offset {
+		// The stream can't be moved, a new one is requested on the next read.
+		_ = f.Close()
+	}
+	f.offset = offset
+	return offset, nil
+}
+
+func (f *openFile) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, &fs.PathError{Op: "readat", Path: f.name, Err: fs.ErrInvalid}
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if off >= f.size {
+		return 0, io.EOF
+	}
+	end := min(off+int64(len(p)), f.size)
+	n, err := f.readRange(f.fs.ctx, p[:end-off], off)
+	if err != nil {
+		return n, &fs.PathError{Op: "readat", Path: f.name, Err: err}
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// readRange fills p with the content starting at the offset, which must not
+// reach past the end of the file. An interrupted download is resumed from
+// where it stopped.
+func (f *openFile) readRange(ctx context.Context, p []byte, off int64) (int, error) {
+	n := 0
+	for resumes := 0; n < len(p); {
+		data, err := f.openRange(ctx, off+int64(n), off+int64(len(p)))
+		if err != nil {
+			return n, err
+		}
+		m, err := io.ReadFull(data, p[n:])
+		_ = data.Close()
+		n += m
+		if err == nil {
+			break
+		}
+		if m > 0 {
+			resumes = 0
+		}
+		if err := f.waitResume(ctx, resumes, err); err != nil {
+			return n, err
+		}
+		resumes++
+	}
+	return n, nil
+}
+
+func (f *openFile) Close() error {
+	if f.prefetch != nil {
+		f.prefetch.Close()
+		f.prefetch = nil
+	}
+	if f.data == nil {
+		return nil
+	}
+	err := f.data.Close()
+	f.data = nil
+	return err
+}
+
+// waitResume waits before resuming an interrupted download, or returns the
+// error of the interruption when the resumes are exhausted.
+func (f *openFile) waitResume(ctx context.Context, resumes int, err error) error {
+	if errors.Is(err, io.EOF) {
+		err = io.ErrUnexpectedEOF
+	}
+	if resumes >= f.fs.opts.DownloadResumes || ctx.Err() != nil {
+		return err
+	}
+	return sleep(ctx, f.fs.retry.delay(resumes+1, nil))
 }
 
-var _ fs.File = &openFile{}
+// openRange requests the content between the start and end offsets. A negative
+// end means the rest of the file. The download URL is refreshed when it was
+// refused, as the pre-authenticated URLs expire.
+func (f *openFile) openRange(ctx context.Context, start, end int64) (io.ReadCloser, error) {
+	f.mu.Lock()
+	downloadURL := f.downloadURL
+	f.mu.Unlock()
+	data, err := f.openURLRange(ctx, downloadURL, start, end)
+	switch errorStatusCode(err) {
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusGone:
+	default:
+		return data, err
+	}
+	if downloadURL, err = f.refreshDownloadURL(ctx); err != nil {
+		return nil, err
+	}
+	return f.openURLRange(ctx, downloadURL, start, end)
+}
+
+// refreshDownloadURL gets a new download URL of the file, unless its content
+// changed.
+func (f *openFile) refreshDownloadURL(ctx context.Context) (string, error) {
+	if f.version != "" {
+		// The versions don't change.
+		downloadURL, err := f.fs.versionDownloadURL(ctx, f.sys.ID, f.version)
+		if err != nil {
+			return "", err
+		}
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.downloadURL = downloadURL
+		return downloadURL, nil
+	}
+	item, err := f.fs.getDriveItemByID(ctx, f.sys.ID)
+	if err != nil {
+		return "", err
+	}
+	if item.CTag != f.sys.CTag || item.Size != f.size {
+		return "", ErrContentChanged
+	}
+	if item.DownloadURL == "" {
+		return "", fmt.Errorf("the file is not downloadable, because the API didn't provide download URL")
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.downloadURL = item.DownloadURL
+	return item.DownloadURL, nil
+}
+
+// openURLRange requests the content between the start and end offsets from
+// the download URL.
+func (f *openFile) openURLRange(ctx context.Context, downloadURL string, start, end int64) (io.ReadCloser, error) {
+	req, err := http.NewRequest("GET", downloadURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case end >= 0:
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end-1))
+	case start > 0:
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", start))
+	}
+	resp, err := f.fs.retry.do(ctx, f.client, req)
+	if err != nil {
+		return nil, err
+	}
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		if size, ok := contentRangeSize(resp.Header.Get("Content-Range")); ok && size != f.size {
+			_ = resp.Body.Close()
+			return nil, ErrContentChanged
+		}
+		return resp.Body, nil
+	case http.StatusOK:
+		if resp.ContentLength >= 0 && resp.ContentLength != f.size {
+			_ = resp.Body.Close()
+			return nil, ErrContentChanged
+		}
+		// The server ignored the Range header, skip the leading bytes ourselves.
+		if _, err := io.CopyN(io.Discard, resp.Body, start); err != nil {
+			_ = resp.Body.Close()
+			return nil, err
+		}
+		if end < 0 {
+			return resp.Body, nil
+		}
+		return struct {
+			io.Reader
+			io.Closer
+		}{io.LimitReader(resp.Body, end-start), resp.Body}, nil
+	default:
+		defer func() { _ = resp.Body.Close() }()
+		return nil, responseError(resp)
+	}
+}
 
-func (f *openFile) Stat() (fs.FileInfo, error)     { return &f.fileInfo, nil }
-func (f *openFile) Read(bytes []byte) (int, error) { return f.data.Read(bytes) }
-func (f *openFile) Close() error                   { return f.data.Close() }
+// contentRangeSize returns the complete length of the content from the
+// Content-Range header, like "bytes 0-99/1234".
+func contentRangeSize(contentRange string) (int64, bool) {
+	_, size, ok := strings.Cut(contentRange, "/")
+	if !ok || size == "*" {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(size, 10, 64)
+	return n, err == nil
+}
 
 type openDir struct {
 	fileInfo
-	fs      *FS
-	dirID   string
-	driveID string
+	fs    *FS
+	dirID string
+	eTag  string
 
-	getItemsOnce sync.Once
-	items        []*driveItem
-	offset       int
+	// listed reports whether the first page of the folder was requested.
+	listed   bool
+	nextLink string
+	// items are the fetched items not returned yet, in the order of the
+	// listing.
+	items []*driveItem
+	// all are all the fetched items, kept for the cache only.
+	all       []*driveItem
+	listStart time.Time
 }
 
 var (
@@ -38,21 +337,20 @@ var (
 
 func (d *openDir) Stat() (fs.FileInfo, error) { return &d.fileInfo, nil }
 
+// ReadDir returns the entries in the order of the listing, which OneDrive
+// sorts by name case-insensitively. The entries returned at once, with count
+// <= 0, are sorted by name like fs.ReadDir sorts them.
 func (d *openDir) ReadDir(count int) ([]fs.DirEntry, error) {
-	var err error
-	d.getItemsOnce.Do(func() {
-		// We must get all the items, because the API does not support pagination.
-		// It does support $top, but not $skip. WTF Microsoft?
-		var items *driveItemsResponse
-		items, err = listDriveItems(d.fs.ctx, d.fs.client, d.driveID, d.dirID)
-		if err == nil {
-			d.items = items.DriveItems
+	// Pages are fetched only when the already fetched items don't suffice.
+	for count <= 0 || len(d.items) < count {
+		if d.listed && d.nextLink == "" {
+			break
+		}
+		if err := d.fetchPage(); err != nil {
+			return nil, err
 		}
-	})
-	if err != nil {
-		return nil, err
 	}
-	n := len(d.items) - d.offset
+	n := len(d.items)
 	if n == 0 && count > 0 {
 		return nil, io.EOF
 	}
@@ -61,29 +359,49 @@ func (d *openDir) ReadDir(count int) ([]fs.DirEntry, error) {
 	}
 	list := make([]fs.DirEntry, n)
 	for i := range list {
-		item := d.items[d.offset+i]
-		mode := fs.FileMode(0o555)
-		if item.Folder != nil {
-			mode |= fs.ModeDir
-		}
-		list[i] = &dirEntry{
-			fileInfo: fileInfo{
-				name:    item.Name,
-				size:    item.Size,
-				mode:    mode,
-				modTime: time.Time(item.LastModifiedDateTime),
-				isDir:   item.Folder != nil,
-			},
-		}
-	}
-	d.offset += n
-	// Some extra sorting, as the Microsoft API can't be trusted
-	slices.SortFunc(list, func(a, b fs.DirEntry) int {
-		return strings.Compare(a.Name(), b.Name())
-	})
+		list[i] = &dirEntry{fileInfo: newFileInfo(d.items[i])}
+	}
+	d.items = d.items[n:]
+	if count <= 0 {
+		slices.SortFunc(list, func(a, b fs.DirEntry) int {
+			return strings.Compare(a.Name(), b.Name())
+		})
+	}
 	return list, nil
 }
 
+// fetchPage appends the next page of the folder listing to the pending items.
+func (d *openDir) fetchPage() error {
+	var (
+		page *driveItemsResponse
+		err  error
+	)
+	if !d.listed {
+		if children, ok := d.fs.cachedChildren(d.fs.ctx, d.dirID); ok {
+			d.listed = true
+			d.items = slices.Clone(children)
+			return nil
+		}
+		d.listStart = time.Now()
+		page, err = d.fs.listDriveItems(d.fs.ctx, d.dirID)
+	} else {
+		page, err = d.fs.listDriveItemsNextPage(d.fs.ctx, d.nextLink)
+	}
+	if err != nil {
+		return err
+	}
+	d.listed = true
+	d.nextLink = page.NextLink
+	d.items = append(d.items, page.DriveItems...)
+	if d.fs.cache != nil {
+		d.all = append(d.all, page.DriveItems...)
+		if d.nextLink == "" {
+			d.fs.cacheChildren(d.dirID, d.eTag, d.all, d.listStart)
+		}
+	}
+	return nil
+}
+
 func (d *openDir) Read([]byte) (int, error) {
 	return 0, &fs.PathError{Op: "read", Path: d.name, Err: fs.ErrInvalid}
 }
@@ -103,6 +421,27 @@ type fileInfo struct {
 	mode    fs.FileMode
 	modTime time.Time
 	isDir   bool
+	sys     *Item
+}
+
+// newFileInfo returns the file info of the item, with the item metadata
+// returned by Sys.
+func newFileInfo(item *driveItem) fileInfo {
+	info := fileInfo{
+		name:    item.Name,
+		size:    item.Size,
+		mode:    0o555,
+		modTime: time.Time(item.LastModifiedDateTime),
+		isDir:   item.Folder != nil,
+		sys:     item.metadata(),
+	}
+	if item.Folder != nil {
+		info.mode |= fs.ModeDir
+	}
+	if item.Root != nil {
+		info.name = "."
+	}
+	return info
 }
 
 func (f *fileInfo) Name() string       { return f.name }
@@ -110,4 +449,9 @@ func (f *fileInfo) Size() int64        { return f.size }
 func (f *fileInfo) Mode() fs.FileMode  { return f.mode }
 func (f *fileInfo) ModTime() time.Time { return f.modTime }
 func (f *fileInfo) IsDir() bool        { return f.isDir }
-func (f *fileInfo) Sys() any           { return nil }
+func (f *fileInfo) Sys() any {
+	if f.sys == nil {
+		return nil
+	}
+	return f.sys
+}
@@ -0,0 +1,215 @@
+package onedrivefs
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"strings"
+	"testing"
+
+	"go.dataddo.com/onedrivefs/onedrivefstest"
+	"go.dataddo.com/onedrivefs/quickxorhash"
+)
+
+func TestFS_Open_lazy(t *testing.T) {
+	fsys, srv := initFakeFileSystem(t, onedrivefstest.Options{})
+	f, err := fsys.Open("README.md")
+	noErr(t, err)
+	t.Cleanup(func() { _ = f.Close() })
+	info, err := f.Stat()
+	noErr(t, err)
+	assertEqual(t, int64(33), info.Size(), "README.md")
+	assertEqual(t, 0, downloads(srv), "downloads before read")
+
+	data, err := io.ReadAll(f)
+	noErr(t, err)
+	assertEqual(t, "This is a test dir for onedrivefs", string(data), "README.md")
+	assertEqual(t, 1, downloads(srv), "downloads after read")
+}
+
+func TestFS_Open_downloadError(t *testing.T) {
+	fsys, srv := initFakeFileSystem(t, onedrivefstest.Options{})
+	f, err := fsys.Open("README.md")
+	noErr(t, err)
+	t.Cleanup(func() { _ = f.Close() })
+
+	srv.InjectFault(onedrivefstest.Fault{
+		Match:      func(r *http.Request) bool { return strings.HasPrefix(r.URL.Path, "/download/") },
+		StatusCode: http.StatusForbidden,
+		Code:       AccessDeniedErrorCode,
+	})
+	_, err = f.Read(make([]byte, 10))
+	var odErr *OneDriveAPIError
+	if !errors.As(err, &odErr) || odErr.StatusCode != http.StatusForbidden || !errors.Is(err, fs.ErrPermission) {
+		t.Fatal("expected accessDenied error, got:", err)
+	}
+	_, err = f.(io.ReaderAt).ReadAt(make([]byte, 10), 5)
+	if !errors.Is(err, fs.ErrPermission) {
+		t.Fatal("expected fs.ErrPermission, got:", err)
+	}
+}
+
+func TestFS_Open_resume(t *testing.T) {
+	fsys, srv := initFakeFileSystem(t, onedrivefstest.Options{})
+	content := bytes.Repeat([]byte("0123456789abcdef"), 4<<10) // 64 KiB
+	noErr(t, srv.WriteFile("big.bin", content))
+	isDownload := func(r *http.Request) bool { return strings.HasPrefix(r.URL.Path, "/download/") }
+
+	t.Run("read", func(t *testing.T) {
+		f, err := fsys.Open("big.bin")
+		noErr(t, err)
+		t.Cleanup(func() { _ = f.Close() })
+		srv.InjectFault(onedrivefstest.Fault{Match: isDownload, Count: 3, Truncate: 10 << 10})
+		got, err := io.ReadAll(f)
+		noErr(t, err)
+		assertEqual(t, true, bytes.Equal(content, got), "big.bin")
+	})
+
+	t.Run("readat", func(t *testing.T) {
+		f, err := fsys.Open("big.bin")
+		noErr(t, err)
+		t.Cleanup(func() { _ = f.Close() })
+		srv.InjectFault(onedrivefstest.Fault{Match: isDownload, Count: 2, Truncate: 1 << 10})
+		got := make([]byte, 10<<10)
+		n, err := f.(io.ReaderAt).ReadAt(got, 100)
+		noErr(t, err)
+		assertEqual(t, len(got), n, "read bytes")
+		assertEqual(t, true, bytes.Equal(content[100:100+len(got)], got), "big.bin")
+	})
+
+	t.Run("expired URL", func(t *testing.T) {
+		f, err := fsys.Open("big.bin")
+		noErr(t, err)
+		t.Cleanup(func() { _ = f.Close() })
+		srv.ExpireDownloadURLs()
+		srv.ResetRequests()
+		got, err := io.ReadAll(f)
+		noErr(t, err)
+		assertEqual(t, true, bytes.Equal(content, got), "big.bin")
+		assertEqual(t, 2, downloads(srv), "downloads")
+	})
+
+	t.Run("changed content", func(t *testing.T) {
+		f, err := fsys.Open("big.bin")
+		noErr(t, err)
+		t.Cleanup(func() { _ = f.Close() })
+		srv.ExpireDownloadURLs()
+		noErr(t, srv.WriteFile("big.bin", content[1:]))
+		t.Cleanup(func() { noErr(t, srv.WriteFile("big.bin", content)) })
+		_, err = io.ReadAll(f)
+		if !errors.Is(err, ErrContentChanged) {
+			t.Fatal("expected ErrContentChanged, got:", err)
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		fsys, err := OpenFS(srv.Client(), DriveOpts{Endpoint: srv.Endpoint(), DownloadResumes: -1})
+		noErr(t, err)
+		f, err := fsys.Open("big.bin")
+		noErr(t, err)
+		t.Cleanup(func() { _ = f.Close() })
+		srv.InjectFault(onedrivefstest.Fault{Match: isDownload, Count: 1, Truncate: 1 << 10})
+		_, err = io.ReadAll(f)
+		if !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Fatal("expected io.ErrUnexpectedEOF, got:", err)
+		}
+	})
+}
+
+func TestFS_Open_checksum(t *testing.T) {
+	fsys, srv := initFakeFileSystem(t, onedrivefstest.Options{})
+	content := []byte("This is a test dir for onedrivefs")
+	info, err := fsys.Stat("README.md")
+	noErr(t, err)
+	h := quickxorhash.New()
+	_, _ = h.Write(content)
+	assertEqual(t, base64.StdEncoding.EncodeToString(h.Sum(nil)), info.Sys().(*Item).Hashes.QuickXorHash, "quickXorHash")
+
+	f, err := fsys.Open("README.md")
+	noErr(t, err)
+	t.Cleanup(func() { _ = f.Close() })
+	// The content is changed after the file was opened, but the size stays
+	// the same.
+	noErr(t, srv.WriteFile("README.md", bytes.ToUpper(content)))
+	_, err = io.ReadAll(f)
+	var checksumErr *ChecksumError
+	if !errors.As(err, &checksumErr) || checksumErr.Algorithm != "quickXorHash" {
+		t.Fatal("expected ChecksumError, got:", err)
+	}
+
+	// The content isn't verified when not read whole.
+	f, err = fsys.Open("README.md")
+	noErr(t, err)
+	t.Cleanup(func() { _ = f.Close() })
+	noErr(t, srv.WriteFile("README.md", content))
+	_, err = f.(io.Seeker).Seek(1, io.SeekStart)
+	noErr(t, err)
+	_, err = io.ReadAll(f)
+	noErr(t, err)
+}
+
+func TestFS_downloadClient(t *testing.T) {
+	_, srv := initFakeFileSystem(t, onedrivefstest.Options{})
+	var apiPaths, downloadPaths []string
+	apiClient := &http.Client{Transport: roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		apiPaths = append(apiPaths, r.URL.Path)
+		return srv.Client().Transport.RoundTrip(r)
+	})}
+	downloadClient := &http.Client{Transport: roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		downloadPaths = append(downloadPaths, r.URL.Path)
+		return srv.Client().Transport.RoundTrip(r)
+	})}
+	fsys, err := OpenFS(apiClient, DriveOpts{Endpoint: srv.Endpoint(), DownloadClient: downloadClient})
+	noErr(t, err)
+	fsys.opts.UploadSessionThreshold = 1 << 10
+
+	_, err = fsys.ReadFile("README.md")
+	noErr(t, err)
+	noErr(t, fsys.WriteFile("big.bin", make([]byte, 2<<10), 0o644))
+	for _, p := range apiPaths {
+		if !strings.HasPrefix(p, "/v1.0/") {
+			t.Error("API client used for", p)
+		}
+	}
+	for _, p := range downloadPaths {
+		if strings.HasPrefix(p, "/v1.0/") {
+			t.Error("download client used for", p)
+		}
+	}
+	assertEqual(t, 2, len(downloadPaths), "download client requests")
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+func Test_responseError(t *testing.T) {
+	resp := &http.Response{
+		StatusCode: http.StatusNotFound,
+		Status:     "404 Not Found",
+		Header:     http.Header{"X-Test": {"1"}},
+		Body:       io.NopCloser(strings.NewReader("<html>not found</html>")),
+	}
+	err := responseError(resp)
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusNotFound || httpErr.Header.Get("X-Test") != "1" {
+		t.Fatal("expected HTTPError, got:", err)
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatal("expected fs.ErrNotExist, got:", err)
+	}
+}
+
+// downloads returns the number of the content downloads served.
+func downloads(srv *onedrivefstest.Server) int {
+	n := 0
+	for _, req := range srv.Requests() {
+		if strings.Contains(req, " /download/") {
+			n++
+		}
+	}
+	return n
+}
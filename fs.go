@@ -8,26 +8,142 @@ import (
 	"io"
 	"io/fs"
 	"net/http"
+	"net/url"
 	"path/filepath"
 	"strings"
-	"time"
+	"syscall"
 )
 
 type FS struct {
-	client *http.Client
-	opts   DriveOpts
-	ctx    context.Context
+	client   *http.Client
+	opts     DriveOpts
+	endpoint *url.URL
+	retry    RetryPolicy
+	ctx      context.Context
+	// cache is shared by the copies made by Context and Sub, nil when
+	// disabled.
+	cache *metadataCache
+	// rootID is the ID of the root folder of an FS returned by Sub, empty for
+	// the drive root.
+	rootID string
 }
 
 type DriveOpts struct {
+	// Drive is the drive the FS operates on, the OneDrive of the signed-in
+	// user by default.
+	Drive Drive
+	// DriveID is the ID of the drive the FS operates on.
+	//
+	// Deprecated: Use Drive with DriveByID.
 	DriveID string
+	// Endpoint is the base URL of the Microsoft Graph API, e.g. one of the
+	// national cloud endpoints like USGovEndpoint. It defaults to
+	// DefaultEndpoint.
+	Endpoint string
+	// Retry configures retrying of requests failed because of throttling or
+	// transient errors. DefaultRetryPolicy is used when nil.
+	Retry *RetryPolicy
+	// UploadSessionThreshold is the file size above which written files are
+	// uploaded in fragments through a resumable upload session. It defaults
+	// to 4 MiB.
+	UploadSessionThreshold int64
+	// UploadFragmentSize is the size of the fragments uploaded in an upload
+	// session. It's rounded down to a multiple of 320 KiB and defaults to
+	// 10 MiB.
+	UploadFragmentSize int64
+	// Cache enables caching of the metadata of the items and of the folder
+	// listings, so e.g. fs.WalkDir followed by ReadFile of the walked files
+	// doesn't request every file again. The cached metadata may not reflect
+	// the changes made by others until they expire. The changes made through
+	// the FS invalidate the cached metadata of the changed items and of their
+	// parent directories. The cache is disabled when nil.
+	Cache *CacheOptions
+	// DownloadClient is the client used for the content downloads and the
+	// upload sessions. Their URLs are pre-authenticated, so it must not be
+	// the authenticated API client, which would send the token to the
+	// storage hosts. DefaultDownloadClient is used when nil.
+	DownloadClient *http.Client
+	// DownloadResumes is how many times a read of a file reconnects after
+	// the download was interrupted, without reading anything in between. The
+	// download continues from the offset it was interrupted at. It defaults
+	// to 3, a negative value disables resuming.
+	DownloadResumes int
+	// DownloadConcurrency is the number of the byte ranges of a file
+	// downloaded in parallel by DownloadTo and by the sequential reads of the
+	// opened files. The reads hold at most this many ranges in memory. The
+	// content is downloaded in a single stream when it's lower than 2.
+	DownloadConcurrency int
+	// DownloadChunkSize is the size of the byte ranges downloaded in
+	// parallel. It defaults to 8 MiB.
+	DownloadChunkSize int64
 }
 
+// Base URLs of the Microsoft Graph API in the global and national clouds.
+//
+// Ref https://learn.microsoft.com/en-us/graph/deployments
+const (
+	// DefaultEndpoint is the Microsoft Graph global service.
+	DefaultEndpoint = "https://graph.microsoft.com/v1.0/"
+	// USGovEndpoint is Microsoft Graph for US Government L4 (GCC High).
+	USGovEndpoint = "https://graph.microsoft.us/v1.0/"
+	// USGovDoDEndpoint is Microsoft Graph for US Government L5 (DOD).
+	USGovDoDEndpoint = "https://dod-graph.microsoft.us/v1.0/"
+	// ChinaEndpoint is Microsoft Graph China operated by 21Vianet.
+	ChinaEndpoint = "https://microsoftgraph.chinacloudapi.cn/v1.0/"
+)
+
 func OpenFS(client *http.Client, opts DriveOpts) (*FS, error) {
+	if opts.Drive == (Drive{}) && opts.DriveID != "" {
+		opts.Drive = DriveByID(opts.DriveID)
+	}
+	if opts.Endpoint == "" {
+		opts.Endpoint = DefaultEndpoint
+	}
+	endpoint, err := url.Parse(opts.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid endpoint: %w", err)
+	}
+	if !endpoint.IsAbs() {
+		return nil, fmt.Errorf("invalid endpoint: %q is not an absolute URL", opts.Endpoint)
+	}
+	// The endpoint is a directory the API paths are resolved against.
+	if !strings.HasSuffix(endpoint.Path, "/") {
+		endpoint.Path += "/"
+	}
+	retry := DefaultRetryPolicy
+	if opts.Retry != nil {
+		retry = *opts.Retry
+	}
+	if opts.UploadSessionThreshold <= 0 {
+		opts.UploadSessionThreshold = defaultUploadSessionThreshold
+	}
+	opts.UploadFragmentSize -= opts.UploadFragmentSize % uploadFragmentUnit
+	if opts.UploadFragmentSize <= 0 {
+		opts.UploadFragmentSize = defaultUploadFragmentSize
+	}
+	if opts.DownloadClient == nil {
+		opts.DownloadClient = DefaultDownloadClient
+	}
+	if opts.DownloadChunkSize <= 0 {
+		opts.DownloadChunkSize = defaultDownloadChunkSize
+	}
+	switch {
+	case opts.DownloadResumes == 0:
+		opts.DownloadResumes = defaultDownloadResumes
+	case opts.DownloadResumes < 0:
+		opts.DownloadResumes = 0
+	}
+	var cache *metadataCache
+	if opts.Cache != nil {
+		cache = newMetadataCache(*opts.Cache)
+	}
 	return &FS{
-		ctx:    context.Background(),
-		client: client,
-		opts:   opts,
+		ctx:      context.Background(),
+		client:   client,
+		opts:     opts,
+		endpoint: endpoint,
+		retry:    retry,
+		cache:    cache,
 	}, nil
 }
 
@@ -36,8 +152,8 @@ var (
 	_ fs.ReadDirFS  = &FS{}
 	_ fs.ReadFileFS = &FS{}
 	_ fs.StatFS     = &FS{}
-	// _ fs.SubFS      = &FS{} // shifts the root directory, won't do now
-	// _ fs.GlobFS     = &FS{} // not implemented
+	_ fs.SubFS      = &FS{}
+	_ fs.GlobFS     = &FS{}
 )
 
 func (f *FS) Context(ctx context.Context) *FS {
@@ -45,12 +161,43 @@ func (f *FS) Context(ctx context.Context) *FS {
 		ctx = context.Background()
 	}
 	return &FS{
-		ctx:    ctx,
-		client: f.client,
-		opts:   f.opts,
+		ctx:      ctx,
+		client:   f.client,
+		opts:     f.opts,
+		endpoint: f.endpoint,
+		retry:    f.retry,
+		cache:    f.cache,
+		rootID:   f.rootID,
 	}
 }
 
+// Sub returns an FS rooted at the directory dir. The directory is resolved
+// once and the requests of the returned FS address the items relative to it
+// by its ID, so the FS keeps working when the directory or its parents are
+// renamed or moved.
+func (f *FS) Sub(dir string) (fs.FS, error) {
+	if err := validatePath(dir); err != nil {
+		return nil, &fs.PathError{Op: "sub", Path: dir, Err: err}
+	}
+	if dir == "." {
+		return f, nil
+	}
+	item, err := f.getDriveItemsByPath(f.ctx, dir)
+	if err != nil {
+		return nil, &fs.PathError{Op: "sub", Path: dir, Err: err}
+	}
+	if item.Folder == nil {
+		return nil, &fs.PathError{Op: "sub", Path: dir, Err: syscall.ENOTDIR}
+	}
+	sub := f.Context(f.ctx)
+	sub.rootID = item.ID
+	return sub, nil
+}
+
+// Open opens the named file or directory. The directories implement
+// fs.ReadDirFile: ReadDir(n) with n > 0 returns the entries in the order of
+// the OneDrive listing, sorted by name case-insensitively, while ReadDir(-1)
+// returns the remaining entries sorted by name like fs.ReadDir.
 func (f *FS) Open(origName string) (fs.File, error) {
 	name := origName
 	if err := validatePath(name); err != nil {
@@ -61,7 +208,7 @@ func (f *FS) Open(origName string) (fs.File, error) {
 		name = "/"
 	}
 	itemPath := strings.TrimPrefix(name, "/")
-	item, err := getDriveItemsByPath(f.ctx, f.client, f.opts.DriveID, itemPath)
+	item, err := f.getDriveItemsByPath(f.ctx, itemPath)
 	if err != nil {
 		if odErr := (&OneDriveAPIError{}); errors.As(err, &odErr) && odErr.Code == ItemNotFoundErrorCode {
 			return nil, fs.ErrNotExist
@@ -69,45 +216,25 @@ func (f *FS) Open(origName string) (fs.File, error) {
 		return nil, err
 	}
 	if item.Folder != nil {
-		name := item.Name
-		if item.Root != nil {
-			name = "."
-		}
 		return &openDir{
-			fs:      f,
-			driveID: f.opts.DriveID,
-			dirID:   item.ID,
-			fileInfo: fileInfo{
-				isDir:   true,
-				name:    name,
-				mode:    0o555 + fs.ModeDir,
-				modTime: time.Time(item.LastModifiedDateTime),
-				size:    item.Size,
-			},
+			fs:       f,
+			dirID:    item.ID,
+			eTag:     item.ETag,
+			fileInfo: newFileInfo(item),
 		}, nil
 	}
 	if item.DownloadURL == "" {
 		return nil, fmt.Errorf("the file is not downloadable, because the API didn't provide download URL")
 	}
-	downloadReq, err := http.NewRequestWithContext(f.ctx, "GET", item.DownloadURL, nil)
-	if err != nil {
-		return nil, err
-	}
-	// create new client to avoid using default client
-	resp, err := (&http.Client{}).Do(downloadReq)
-	if err != nil {
-		return nil, err
-	}
-
+	// The content is downloaded by the first read, so opening a file only to
+	// stat it is cheap.
+	info := newFileInfo(item)
 	return &openFile{
-		fileInfo: fileInfo{
-			isDir:   false,
-			name:    item.Name,
-			size:    item.Size,
-			mode:    0o555,
-			modTime: time.Time(item.LastModifiedDateTime),
-		},
-		data: resp.Body,
+		fileInfo:    info,
+		fs:          f,
+		client:      f.opts.DownloadClient,
+		downloadURL: item.DownloadURL,
+		checksum:    newContentChecksum(info.sys.Hashes),
 	}, nil
 }
 
@@ -152,33 +279,45 @@ func (f *FS) Stat(name string) (fs.FileInfo, error) {
 		name = "/"
 	}
 	itemPath := strings.TrimPrefix(name, "/")
-	item, err := getDriveItemsByPath(f.ctx, f.client, f.opts.DriveID, itemPath)
+	item, err := f.getDriveItemsByPath(f.ctx, itemPath)
 	if err != nil {
 		if odErr := (&OneDriveAPIError{}); errors.As(err, &odErr) && odErr.Code == "itemNotFound" {
 			return nil, fs.ErrNotExist
 		}
 		return nil, err
 	}
+	info := newFileInfo(item)
+	return &info, nil
+}
+
+// namedItem gets the named item for the operation, returning the errors as
+// *fs.PathError.
+func (f *FS) namedItem(op, name string) (*driveItem, error) {
+	if err := validatePath(name); err != nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	itemPath := name
+	if name == "." {
+		itemPath = ""
+	}
+	item, err := f.getDriveItemsByPath(f.ctx, itemPath)
+	if err != nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	return item, nil
+}
+
+// fileItem gets the named item like namedItem, failing when it's a
+// directory.
+func (f *FS) fileItem(op, name string) (*driveItem, error) {
+	item, err := f.namedItem(op, name)
+	if err != nil {
+		return nil, err
+	}
 	if item.Folder != nil {
-		name := item.Name
-		if item.Root != nil {
-			name = "."
-		}
-		return &fileInfo{
-			isDir:   true,
-			name:    name,
-			mode:    0o555 + fs.ModeDir,
-			modTime: time.Time(item.LastModifiedDateTime),
-			size:    item.Size,
-		}, nil
+		return nil, &fs.PathError{Op: op, Path: name, Err: syscall.EISDIR}
 	}
-	return &fileInfo{
-		isDir:   false,
-		name:    item.Name,
-		size:    item.Size,
-		mode:    0o555,
-		modTime: time.Time(item.LastModifiedDateTime),
-	}, nil
+	return item, nil
 }
 
 func validatePath(path string) error {
@@ -3,18 +3,23 @@ package onedrivefs
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"io/fs"
 	"net/http"
 	"os"
 	"reflect"
 	"slices"
+	"strings"
+	"syscall"
 	"testing"
 	"testing/fstest"
 	"time"
 
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/microsoft"
+
+	"go.dataddo.com/onedrivefs/onedrivefstest"
 )
 
 func TestFS_Open(t *testing.T) {
@@ -173,6 +178,38 @@ func TestFS_ReadDir(t *testing.T) {
 	}
 }
 
+func TestFS_Seek(t *testing.T) {
+	fsys := initFileSystem(t)
+	f, err := fsys.Open("subdir1/subdir2/foo.json")
+	noErr(t, err)
+	t.Cleanup(func() { _ = f.Close() })
+	file, ok := f.(interface {
+		io.ReadSeeker
+		io.ReaderAt
+	})
+	if !ok {
+		t.Fatalf("expected seekable file, got %T", f)
+	}
+
+	pos, err := file.Seek(-5, io.SeekEnd)
+	noErr(t, err)
+	assertEqual(t, int64(4), pos, "foo.json")
+	rest, err := io.ReadAll(file)
+	noErr(t, err)
+	assertEqual(t, `JSON"`, string(rest), "foo.json")
+
+	buf := make([]byte, 2)
+	n, err := file.ReadAt(buf, 1)
+	noErr(t, err)
+	assertEqual(t, "is", string(buf[:n]), "foo.json")
+
+	n, err = file.ReadAt(buf, 8)
+	if !errors.Is(err, io.EOF) {
+		t.Fatal("expected io.EOF, got:", err)
+	}
+	assertEqual(t, `"`, string(buf[:n]), "foo.json")
+}
+
 func TestFS_TestFS(t *testing.T) {
 	fsys := initFileSystem(t)
 	err := fstest.TestFS(fsys,
@@ -183,11 +220,156 @@ func TestFS_TestFS(t *testing.T) {
 	noErr(t, err)
 }
 
+func TestFS_TestFS_offline(t *testing.T) {
+	// A tiny page size makes every listing span several pages.
+	fsys, _ := initFakeFileSystem(t, onedrivefstest.Options{PageSize: 1})
+	err := fstest.TestFS(fsys,
+		"README.md",
+		"subdir1/subdir2/foo.json",
+		"subdir1/subdir2/foo-json",
+	)
+	noErr(t, err)
+}
+
+func TestFS_ReadDir_paging(t *testing.T) {
+	fsys, srv := initFakeFileSystem(t, onedrivefstest.Options{PageSize: 100})
+	for i := range 250 {
+		noErr(t, srv.WriteFile(fmt.Sprintf("big/%03d.csv", 249-i), nil))
+	}
+	f, err := fsys.Open("big")
+	noErr(t, err)
+	t.Cleanup(func() { _ = f.Close() })
+	dir := f.(fs.ReadDirFile)
+
+	srv.ResetRequests()
+	first, err := dir.ReadDir(10)
+	noErr(t, err)
+	assertEqual(t, 1, len(srv.Requests()), "requests of the first batch")
+	rest, err := dir.ReadDir(-1)
+	noErr(t, err)
+	assertEqual(t, 3, len(srv.Requests()), "requests of the whole listing")
+
+	entries := append(first, rest...)
+	assertEqual(t, 250, len(entries), "big")
+	for i, entry := range entries {
+		assertEqual(t, fmt.Sprintf("%03d.csv", i), entry.Name(), entry.Name())
+	}
+	_, err = dir.ReadDir(1)
+	if !errors.Is(err, io.EOF) {
+		t.Fatal("expected io.EOF, got:", err)
+	}
+}
+
+func TestFS_retry(t *testing.T) {
+	fsys, srv := initFakeFileSystem(t, onedrivefstest.Options{})
+	srv.Throttle(2, 0)
+	_, err := fsys.Stat("README.md")
+	noErr(t, err)
+
+	srv.Throttle(3, 0)
+	_, err = fsys.Stat("README.md")
+	var odErr *OneDriveAPIError
+	if !errors.As(err, &odErr) || odErr.Code != ActivityLimitReachedErrorCode {
+		t.Fatal("expected activityLimitReached error, got:", err)
+	}
+}
+
+func TestOpenFS_endpoint(t *testing.T) {
+	_, err := OpenFS(http.DefaultClient, DriveOpts{Endpoint: "graph.example.com/v1.0"})
+	if err == nil {
+		t.Fatal("expected an error for a relative endpoint")
+	}
+
+	// Two FS in the same process, each talking to its own endpoint, given
+	// with and without the trailing slash.
+	_, srv1 := initFakeFileSystem(t, onedrivefstest.Options{})
+	_, srv2 := initFakeFileSystem(t, onedrivefstest.Options{})
+	noErr(t, srv2.WriteFile("only-in-2.txt", nil))
+	fsys1, err := OpenFS(srv1.Client(), DriveOpts{Endpoint: srv1.Endpoint()})
+	noErr(t, err)
+	fsys2, err := OpenFS(srv2.Client(), DriveOpts{Endpoint: strings.TrimSuffix(srv2.Endpoint(), "/")})
+	noErr(t, err)
+
+	_, err = fsys1.Stat("only-in-2.txt")
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatal("expected fs.ErrNotExist, got:", err)
+	}
+	_, err = fsys2.Stat("only-in-2.txt")
+	noErr(t, err)
+}
+
+func TestOpenFS_drive(t *testing.T) {
+	tests := []struct {
+		name       string
+		drive      Drive
+		driveID    string
+		wantPrefix string
+	}{
+		{name: "zero value", drive: Drive{}, wantPrefix: "/v1.0/me/drive/"},
+		{name: "me", drive: MyDrive(), wantPrefix: "/v1.0/me/drive/"},
+		{name: "drive ID", drive: DriveByID("b!abc"), wantPrefix: "/v1.0/drives/b%21abc/"},
+		{name: "deprecated drive ID", driveID: "b!abc", wantPrefix: "/v1.0/drives/b%21abc/"},
+		{name: "user", drive: UserDrive("user@example.com"), wantPrefix: "/v1.0/users/user@example.com/drive/"},
+		{name: "group", drive: GroupDrive("0d5c"), wantPrefix: "/v1.0/groups/0d5c/drive/"},
+		{name: "site", drive: SiteDrive("example.sharepoint.com,1,2"), wantPrefix: "/v1.0/sites/example.sharepoint.com,1,2/drive/"},
+		{name: "site library", drive: SiteLibrary("site", "lib"), wantPrefix: "/v1.0/sites/site/drives/lib/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, srv := initFakeFileSystem(t, onedrivefstest.Options{})
+			fsys, err := OpenFS(srv.Client(), DriveOpts{Drive: tt.drive, DriveID: tt.driveID, Endpoint: srv.Endpoint()})
+			noErr(t, err)
+			_, err = fs.ReadFile(fsys, "subdir1/subdir2/foo.json")
+			noErr(t, err)
+			_, err = fs.ReadDir(fsys, "subdir1")
+			noErr(t, err)
+			for _, req := range srv.Requests() {
+				if _, path, _ := strings.Cut(req, " "); strings.HasPrefix(path, "/v1.0/") && !strings.HasPrefix(path, tt.wantPrefix) {
+					t.Errorf("want request prefixed with %q, got %q", tt.wantPrefix, req)
+				}
+			}
+		})
+	}
+}
+
+func TestFS_Sub(t *testing.T) {
+	fsys, srv := initFakeFileSystem(t, onedrivefstest.Options{})
+	sub, err := fs.Sub(fsys, "subdir1")
+	noErr(t, err)
+	subdir2, err := fs.Sub(sub, "subdir2")
+	noErr(t, err)
+
+	// The subdirectories are addressed by ID, so renaming them doesn't matter.
+	noErr(t, fsys.Rename("subdir1", "renamed"))
+	srv.ResetRequests()
+	got, err := fs.ReadFile(sub, "subdir2/foo.json")
+	noErr(t, err)
+	assertEqual(t, `"is JSON"`, string(got), "subdir2/foo.json")
+	if req := srv.Requests()[0]; !strings.Contains(req, "/items/") || !strings.Contains(req, ":/subdir2/foo.json") {
+		t.Errorf("want request anchored on the item ID, got %q", req)
+	}
+	noErr(t, fstest.TestFS(subdir2, "foo.json", "foo.csv", "foo-json"))
+
+	noErr(t, sub.(*FS).WriteFile("new.txt", []byte("new"), 0o644))
+	got, err = srv.ReadFile("renamed/new.txt")
+	noErr(t, err)
+	assertEqual(t, "new", string(got), "renamed/new.txt")
+
+	_, err = fs.Sub(fsys, "missing")
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatal("expected fs.ErrNotExist, got:", err)
+	}
+	_, err = fs.Sub(fsys, "README.md")
+	if !errors.Is(err, syscall.ENOTDIR) {
+		t.Fatal("expected syscall.ENOTDIR, got:", err)
+	}
+}
+
 func TestFS_Context(t *testing.T) {
 	ctx, cancel := context.WithTimeout(t.Context(), time.Second)
 	defer cancel()
 	client := initClient(t)
-	fileSystem, err := OpenFS(client, DriveOpts{DriveID: ""})
+	fileSystem, err := OpenFS(client, DriveOpts{Drive: MyDrive(), Endpoint: testEndpoint()})
 	noErr(t, err)
 	fileSystem = fileSystem.Context(ctx)
 	err = fs.WalkDir(fileSystem, ".", func(path string, _ fs.DirEntry, err error) error {
@@ -225,13 +407,30 @@ func initClient(t *testing.T) *http.Client {
 func initFileSystem(t *testing.T) fs.FS {
 	authClient := initClient(t)
 	var fsys fs.FS
-	fsys, err := OpenFS(authClient, DriveOpts{DriveID: ""})
+	fsys, err := OpenFS(authClient, DriveOpts{Drive: MyDrive(), Endpoint: testEndpoint()})
 	noErr(t, err)
 	fsys, err = fs.Sub(fsys, os.Getenv("ONEDRIVE_TEST_SUBDIR"))
 	noErr(t, err)
 	return fsys
 }
 
+// initFakeFileSystem returns an FS served by a fake server with the same
+// content Test_createEnv prepares in OneDrive.
+func initFakeFileSystem(t *testing.T, opts onedrivefstest.Options) (*FS, *onedrivefstest.Server) {
+	srv := onedrivefstest.NewServer(opts)
+	t.Cleanup(srv.Close)
+	noErr(t, srv.WriteFile("README.md", []byte(`This is a test dir for onedrivefs`)))
+	noErr(t, srv.WriteFile("subdir1/subdir2/foo.json", []byte(`"is JSON"`)))
+	noErr(t, srv.WriteFile("subdir1/subdir2/foo.csv", []byte("foo,bar\n1,2\n")))
+	noErr(t, srv.WriteFile("subdir1/subdir2/foo-json", []byte(`not JSON`)))
+	fsys, err := OpenFS(srv.Client(), DriveOpts{
+		Endpoint: srv.Endpoint(),
+		Retry:    &RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond},
+	})
+	noErr(t, err)
+	return fsys, srv
+}
+
 func requireFileInfoEqual(t *testing.T, want fileInfo, stat fs.FileInfo) {
 	t.Helper()
 	name := stat.Name()
@@ -263,3 +462,33 @@ func noErr(t *testing.T, err error, args ...any) {
 		t.Fatal(append([]any{err}, args...)...)
 	}
 }
+
+func TestFS_ReadDir_order(t *testing.T) {
+	fsys, srv := initFakeFileSystem(t, onedrivefstest.Options{PageSize: 1})
+	for _, name := range []string{"c.csv", "B.csv", "a.csv"} {
+		noErr(t, srv.WriteFile("mixed/"+name, nil))
+	}
+	names := func(entries []fs.DirEntry) string {
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		return strings.Join(names, " ")
+	}
+
+	// The batches keep the case-insensitive order of the listing across the
+	// pages.
+	f, err := fsys.Open("mixed")
+	noErr(t, err)
+	t.Cleanup(func() { _ = f.Close() })
+	first, err := f.(fs.ReadDirFile).ReadDir(2)
+	noErr(t, err)
+	rest, err := f.(fs.ReadDirFile).ReadDir(2)
+	noErr(t, err)
+	assertEqual(t, "a.csv B.csv c.csv", names(append(first, rest...)), "batches")
+
+	// The whole listing is sorted like fs.ReadDir sorts it.
+	entries, err := fsys.ReadDir("mixed")
+	noErr(t, err)
+	assertEqual(t, "B.csv a.csv c.csv", names(entries), "ReadDir")
+}
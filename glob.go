@@ -0,0 +1,162 @@
+package onedrivefs
+
+import (
+	"errors"
+	"path"
+	"slices"
+	"strings"
+)
+
+// globMatch is a path matched by a glob pattern.
+type globMatch struct {
+	name  string
+	isDir bool
+}
+
+// Glob returns the names of all files matching the pattern, the same as
+// fs.Glob. Unlike fs.Glob, it doesn't list the directories matched by the
+// pattern that are files, it checks the literal names by a single request
+// instead of listing their directories, and it asks OneDrive to list only the
+// items starting with the literal prefix of the name pattern, e.g. "report-"
+// for "report-*.csv".
+func (f *FS) Glob(pattern string) ([]string, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+	found, err := f.glob(pattern, 0)
+	if err != nil {
+		return nil, err
+	}
+	var matches []string
+	for _, m := range found {
+		matches = append(matches, m.name)
+	}
+	return matches, nil
+}
+
+// glob follows the algorithm of fs.Glob, so the results are identical.
+func (f *FS) glob(pattern string, depth int) ([]globMatch, error) {
+	// The same limit as fs.Glob has to prevent stack exhaustion.
+	const pathSeparatorsLimit = 10000
+	if depth > pathSeparatorsLimit {
+		return nil, path.ErrBadPattern
+	}
+	if !hasMeta(pattern) {
+		info, err := f.Stat(pattern)
+		if err != nil {
+			return nil, nil
+		}
+		return []globMatch{{name: pattern, isDir: info.IsDir()}}, nil
+	}
+
+	dir, file := path.Split(pattern)
+	dir = cleanGlobPath(dir)
+	if !hasMeta(dir) {
+		return f.globDir(dir, file, nil)
+	}
+	// Prevent infinite recursion, see fs.Glob.
+	if dir == pattern {
+		return nil, path.ErrBadPattern
+	}
+	dirs, err := f.glob(dir, depth+1)
+	if err != nil {
+		return nil, err
+	}
+	var matches []globMatch
+	for _, d := range dirs {
+		// fs.Glob fails to list the files, and ignores the error.
+		if !d.isDir {
+			continue
+		}
+		if matches, err = f.globDir(d.name, file, matches); err != nil {
+			return matches, err
+		}
+	}
+	return matches, nil
+}
+
+// globDir appends the items of the directory matching the name pattern to
+// matches. Like fs.Glob, it ignores the errors of the requests.
+func (f *FS) globDir(dir, pattern string, matches []globMatch) ([]globMatch, error) {
+	if validatePath(dir) != nil {
+		return matches, nil
+	}
+	if !hasMeta(pattern) {
+		// A literal name matches at most one item, no need to list them all.
+		if pattern == "" || pattern == "." || pattern == ".." {
+			return matches, nil
+		}
+		name := path.Join(dir, pattern)
+		item, err := f.getDriveItemsByPath(f.ctx, name)
+		// OneDrive finds the names case-insensitively, path.Match doesn't.
+		if err != nil || item.Name != pattern {
+			return matches, nil
+		}
+		return append(matches, globMatch{name: name, isDir: item.Folder != nil}), nil
+	}
+
+	items, err := f.listGlobDir(dir, literalPrefix(pattern))
+	if err != nil {
+		return matches, nil
+	}
+	for _, item := range items {
+		matched, err := path.Match(pattern, item.Name)
+		if err != nil {
+			return matches, err
+		}
+		if matched {
+			matches = append(matches, globMatch{name: path.Join(dir, item.Name), isDir: item.Folder != nil})
+		}
+	}
+	return matches, nil
+}
+
+// listGlobDir lists the items of the directory whose names start with the
+// prefix, sorted by name. It falls back to listing all the items when OneDrive
+// refuses to filter them.
+func (f *FS) listGlobDir(dir, prefix string) ([]*driveItem, error) {
+	if dir == "." {
+		dir = ""
+	}
+	page, err := f.listDriveItemsByPath(f.ctx, dir, prefix)
+	if odErr := (&OneDriveAPIError{}); prefix != "" && errors.As(err, &odErr) &&
+		(odErr.Code == InvalidRequestErrorCode || odErr.Code == NotSupportedErrorCode) {
+		page, err = f.listDriveItemsByPath(f.ctx, dir, "")
+	}
+	if err != nil {
+		return nil, err
+	}
+	items := page.DriveItems
+	for page.NextLink != "" {
+		if page, err = f.listDriveItemsNextPage(f.ctx, page.NextLink); err != nil {
+			return nil, err
+		}
+		items = append(items, page.DriveItems...)
+	}
+	slices.SortStableFunc(items, func(a, b *driveItem) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return items, nil
+}
+
+// literalPrefix returns the part of the pattern before the first special
+// character.
+func literalPrefix(pattern string) string {
+	if i := strings.IndexAny(pattern, `*?[\`); i >= 0 {
+		return pattern[:i]
+	}
+	return pattern
+}
+
+func cleanGlobPath(path string) string {
+	switch path {
+	case "":
+		return "."
+	default:
+		return path[0 : len(path)-1] // chop off trailing separator
+	}
+}
+
+func hasMeta(path string) bool {
+	return strings.ContainsAny(path, `*?[\`)
+}
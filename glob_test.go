@@ -0,0 +1,63 @@
+package onedrivefs
+
+import (
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+	"testing"
+
+	"go.dataddo.com/onedrivefs/onedrivefstest"
+)
+
+func TestFS_Glob(t *testing.T) {
+	for _, disableFilter := range []bool{false, true} {
+		fsys, srv := initFakeFileSystem(t, onedrivefstest.Options{PageSize: 2, DisableFilter: disableFilter})
+		for _, name := range []string{"subdir1/Foo.json", "subdir1/subdir3/foo.json", "reports/report-1.csv", "reports/report-2.csv", "reports/Report-3.csv", "reports/it's.csv"} {
+			noErr(t, srv.WriteFile(name, nil))
+		}
+		patterns := []string{
+			"*",
+			"README.md",
+			"readme.md",
+			"missing",
+			"*/*",
+			"subdir1/*/foo.json",
+			"subdir1/*/FOO.JSON",
+			"subdir1/*/*.json",
+			`*/subdir2/foo[.\-]*`,
+			"reports/report-*.csv",
+			"reports/it'*",
+			"README.md/*",
+			"*/..",
+			"sub*/sub*2",
+			`reports/report-\1.csv`,
+		}
+		for _, pattern := range patterns {
+			want, err := fs.Glob(onlyFS{fsys}, pattern)
+			noErr(t, err)
+			got, err := fsys.Glob(pattern)
+			noErr(t, err)
+			if !slices.Equal(want, got) {
+				t.Errorf("Glob(%q) with disabled filter %v: want %q, got %q", pattern, disableFilter, want, got)
+			}
+		}
+		if _, err := fsys.Glob("["); err != path.ErrBadPattern {
+			t.Errorf("expected path.ErrBadPattern, got: %v", err)
+		}
+
+		srv.ResetRequests()
+		_, err := fsys.Glob("reports/report-*.csv")
+		noErr(t, err)
+		filtered := slices.ContainsFunc(srv.Requests(), func(req string) bool {
+			return strings.Contains(req, "filter=startswith")
+		})
+		assertEqual(t, true, filtered, "filter requested")
+	}
+}
+
+// onlyFS hides all the methods of FS, except Open, so fs.Glob uses its generic
+// implementation.
+type onlyFS struct {
+	fs.FS
+}
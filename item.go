@@ -0,0 +1,100 @@
+package onedrivefs
+
+import "time"
+
+// Item is the OneDrive metadata of a file or a directory. It's returned by the
+// Sys method of the fs.FileInfo values of the FS.
+//
+// OneDrive API docs: https://learn.microsoft.com/en-us/graph/api/resources/driveitem
+type Item struct {
+	ID   string
+	Name string
+	// ETag changes with any change of the item, CTag only with a change of
+	// its content.
+	ETag        string
+	CTag        string
+	Size        int64
+	Description string
+	// WebURL is the URL of the item in the OneDrive web UI.
+	WebURL string
+	// MimeType is the type of the file content, empty for directories.
+	MimeType string
+	// Hashes are the hashes of the file content, empty for directories.
+	Hashes               Hashes
+	CreatedDateTime      time.Time
+	LastModifiedDateTime time.Time
+	CreatedBy            IdentitySet
+	LastModifiedBy       IdentitySet
+	// Parent references the parent directory, it's empty for the drive root.
+	Parent ItemReference
+}
+
+// Hashes are the hashes of a file content. Which of them are available
+// depends on the type of the drive. The SHA hashes are hex encoded,
+// QuickXorHash is base64 encoded.
+//
+// OneDrive API docs: https://learn.microsoft.com/en-us/graph/api/resources/hashes
+type Hashes struct {
+	QuickXorHash string `json:"quickXorHash,omitempty"`
+	SHA1Hash     string `json:"sha1Hash,omitempty"`
+	SHA256Hash   string `json:"sha256Hash,omitempty"`
+	CRC32Hash    string `json:"crc32Hash,omitempty"`
+}
+
+// IdentitySet is a set of identities associated with an action, e.g. the
+// modification of an item.
+//
+// OneDrive API docs: https://learn.microsoft.com/en-us/graph/api/resources/identityset
+type IdentitySet struct {
+	Application *Identity `json:"application,omitempty"`
+	Device      *Identity `json:"device,omitempty"`
+	User        *Identity `json:"user,omitempty"`
+}
+
+// Identity is a user, a device or an application.
+//
+// OneDrive API docs: https://learn.microsoft.com/en-us/graph/api/resources/identity
+type Identity struct {
+	ID          string `json:"id,omitempty"`
+	DisplayName string `json:"displayName,omitempty"`
+	Email       string `json:"email,omitempty"`
+}
+
+// ItemReference references an item by its ID and path.
+//
+// OneDrive API docs: https://learn.microsoft.com/en-us/graph/api/resources/itemreference
+type ItemReference struct {
+	DriveID   string `json:"driveId,omitempty"`
+	DriveType string `json:"driveType,omitempty"`
+	ID        string `json:"id,omitempty"`
+	Name      string `json:"name,omitempty"`
+	// Path is the percent-encoded path of the item in the drive, e.g.
+	// "/drive/root:/dir".
+	Path   string `json:"path,omitempty"`
+	SiteID string `json:"siteId,omitempty"`
+}
+
+// metadata returns the exported metadata of the item.
+func (d *driveItem) metadata() *Item {
+	item := &Item{
+		ID:                   d.ID,
+		Name:                 d.Name,
+		ETag:                 d.ETag,
+		CTag:                 d.CTag,
+		Size:                 d.Size,
+		Description:          d.Description,
+		WebURL:               d.WebURL,
+		CreatedDateTime:      time.Time(d.CreatedDateTime),
+		LastModifiedDateTime: time.Time(d.LastModifiedDateTime),
+		CreatedBy:            d.CreatedBy,
+		LastModifiedBy:       d.LastModifiedBy,
+	}
+	if d.File != nil {
+		item.MimeType = d.File.MimeType
+		item.Hashes = d.File.Hashes
+	}
+	if d.ParentReference != nil {
+		item.Parent = *d.ParentReference
+	}
+	return item
+}
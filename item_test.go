@@ -0,0 +1,52 @@
+package onedrivefs
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/fs"
+	"testing"
+
+	"go.dataddo.com/onedrivefs/onedrivefstest"
+)
+
+func TestFileInfo_Sys(t *testing.T) {
+	fsys, _ := initFakeFileSystem(t, onedrivefstest.Options{})
+	const name = "subdir1/subdir2/foo.json"
+
+	stat, err := fsys.Stat(name)
+	noErr(t, err)
+	f, err := fsys.Open(name)
+	noErr(t, err)
+	t.Cleanup(func() { _ = f.Close() })
+	openStat, err := f.Stat()
+	noErr(t, err)
+	entries, err := fs.ReadDir(fsys, "subdir1/subdir2")
+	noErr(t, err)
+	var entryStat fs.FileInfo
+	for _, entry := range entries {
+		if entry.Name() == "foo.json" {
+			entryStat, err = entry.Info()
+			noErr(t, err)
+		}
+	}
+	parent, err := fsys.Stat("subdir1/subdir2")
+	noErr(t, err)
+	parentItem := parent.Sys().(*Item)
+
+	for _, info := range []fs.FileInfo{stat, openStat, entryStat} {
+		item, ok := info.Sys().(*Item)
+		if !ok {
+			t.Fatalf("want *Item, got %T", info.Sys())
+		}
+		if item.ID == "" || item.ETag == "" || item.CTag == "" || item.WebURL == "" {
+			t.Errorf("want ID, eTag, cTag and web URL, got %+v", item)
+		}
+		assertEqual(t, "foo.json", item.Name, name)
+		assertEqual(t, "application/json", item.MimeType, name)
+		assertEqual(t, fmt.Sprintf("%X", sha256.Sum256([]byte(`"is JSON"`))), item.Hashes.SHA256Hash, name)
+		assertEqual(t, parentItem.ID, item.Parent.ID, name)
+		assertEqual(t, "/drive/root:/subdir1/subdir2", item.Parent.Path, name)
+		assertEqual(t, "Fake User", item.LastModifiedBy.User.DisplayName, name)
+		assertEqual(t, info.ModTime(), item.LastModifiedDateTime, name)
+	}
+}
@@ -0,0 +1,191 @@
+package onedrivefs
+
+import (
+	"errors"
+	"io/fs"
+	"net/url"
+	"time"
+)
+
+// LinkType is the kind of access granted by a sharing link.
+type LinkType string
+
+const (
+	// LinkView grants read-only access.
+	LinkView LinkType = "view"
+	// LinkEdit grants read-write access.
+	LinkEdit LinkType = "edit"
+	// LinkEmbed is a read-only link for embedding the item in web pages. It's
+	// available for OneDrive personal only.
+	LinkEmbed LinkType = "embed"
+)
+
+// LinkScope is the set of the users a sharing link grants access to.
+type LinkScope string
+
+const (
+	// LinkAnonymous grants access to anyone with the link.
+	LinkAnonymous LinkScope = "anonymous"
+	// LinkOrganization grants access to the signed-in users of the
+	// organization of the drive.
+	LinkOrganization LinkScope = "organization"
+)
+
+// LinkOptions configures a sharing link created by FS.CreateLink.
+type LinkOptions struct {
+	Type LinkType
+	// Scope defaults to the default scope of the tenant when empty.
+	Scope LinkScope
+	// Expiration is when the link stops granting access. The link doesn't
+	// expire when zero, unless the tenant policy requires it.
+	Expiration time.Time
+	// Password protects an anonymous link, when supported by the drive.
+	Password string
+}
+
+// Link is a sharing link of an item.
+//
+// OneDrive API docs: https://learn.microsoft.com/en-us/graph/api/resources/permission
+type Link struct {
+	// ID identifies the link among the permissions of the item, see
+	// FS.RevokeLink.
+	ID    string
+	Type  LinkType
+	Scope LinkScope
+	// URL is the link to be handed to the users.
+	URL string
+	// Roles are the granted roles, e.g. "read" or "write".
+	Roles []string
+	// Expiration is zero when the link doesn't expire.
+	Expiration  time.Time
+	HasPassword bool
+	// InheritedFrom references the ancestor directory the link is inherited
+	// from, it's empty for the links of the item itself.
+	InheritedFrom ItemReference
+}
+
+type permission struct {
+	ID    string   `json:"id"`
+	Roles []string `json:"roles"`
+	Link  *struct {
+		Type   LinkType  `json:"type"`
+		Scope  LinkScope `json:"scope"`
+		WebURL string    `json:"webUrl"`
+	} `json:"link"`
+	ExpirationDateTime *dateTimeOffset `json:"expirationDateTime"`
+	HasPassword        bool            `json:"hasPassword"`
+	InheritedFrom      *ItemReference  `json:"inheritedFrom"`
+}
+
+func (p *permission) link() Link {
+	link := Link{
+		ID:          p.ID,
+		Type:        p.Link.Type,
+		Scope:       p.Link.Scope,
+		URL:         p.Link.WebURL,
+		Roles:       p.Roles,
+		HasPassword: p.HasPassword,
+	}
+	if p.ExpirationDateTime != nil {
+		link.Expiration = time.Time(*p.ExpirationDateTime)
+	}
+	if p.InheritedFrom != nil {
+		link.InheritedFrom = *p.InheritedFrom
+	}
+	return link
+}
+
+type permissionsResponse struct {
+	Permissions []*permission `json:"value"`
+	NextLink    string        `json:"@odata.nextLink"`
+}
+
+// CreateLink creates a sharing link of the named file or directory. OneDrive
+// returns the existing link when the item already has one of the same type
+// and scope. When the tenant policy forbids the link, the error wraps
+// an *OneDriveAPIError, usually with AccessDeniedErrorCode or
+// NotAllowedErrorCode.
+//
+// OneDrive API docs: https://learn.microsoft.com/en-us/graph/api/driveitem-createlink
+func (f *FS) CreateLink(name string, opts LinkOptions) (*Link, error) {
+	item, err := f.namedItem("link", name)
+	if err != nil {
+		return nil, err
+	}
+	payload := struct {
+		Type               LinkType  `json:"type"`
+		Scope              LinkScope `json:"scope,omitempty"`
+		ExpirationDateTime string    `json:"expirationDateTime,omitempty"`
+		Password           string    `json:"password,omitempty"`
+	}{
+		Type:     opts.Type,
+		Scope:    opts.Scope,
+		Password: opts.Password,
+	}
+	if !opts.Expiration.IsZero() {
+		payload.ExpirationDateTime = opts.Expiration.UTC().Format(time.RFC3339)
+	}
+	req, err := f.newJSONRequest("POST", f.itemIDURL(item.ID, "createLink"), payload)
+	if err != nil {
+		return nil, &fs.PathError{Op: "link", Path: name, Err: err}
+	}
+	var perm *permission
+	if err := f.doRequest(f.ctx, req, &perm); err != nil {
+		return nil, &fs.PathError{Op: "link", Path: name, Err: err}
+	}
+	if perm.Link == nil {
+		return nil, &fs.PathError{Op: "link", Path: name, Err: errors.New("the API returned no link")}
+	}
+	link := perm.link()
+	return &link, nil
+}
+
+// Links returns the sharing links of the named file or directory, including
+// the ones inherited from its parents. The other permissions, like the
+// invitations of users, are skipped.
+//
+// OneDrive API docs: https://learn.microsoft.com/en-us/graph/api/driveitem-list-permissions
+func (f *FS) Links(name string) ([]Link, error) {
+	item, err := f.namedItem("links", name)
+	if err != nil {
+		return nil, err
+	}
+	var links []Link
+	for apiURL := f.itemIDURL(item.ID, "permissions"); apiURL != ""; {
+		req, err := f.newRequest("GET", apiURL, nil)
+		if err != nil {
+			return nil, &fs.PathError{Op: "links", Path: name, Err: err}
+		}
+		var page *permissionsResponse
+		if err := f.doRequest(f.ctx, req, &page); err != nil {
+			return nil, &fs.PathError{Op: "links", Path: name, Err: err}
+		}
+		for _, perm := range page.Permissions {
+			if perm.Link != nil {
+				links = append(links, perm.link())
+			}
+		}
+		apiURL = page.NextLink
+	}
+	return links, nil
+}
+
+// RevokeLink deletes the sharing link of the ID from the named file or
+// directory. The inherited links can be revoked only on the items they are
+// inherited from.
+//
+// OneDrive API docs: https://learn.microsoft.com/en-us/graph/api/permission-delete
+func (f *FS) RevokeLink(name, id string) error {
+	item, err := f.namedItem("revoke", name)
+	if err != nil {
+		return err
+	}
+	req, err := f.newRequest("DELETE", f.itemIDURL(item.ID, "permissions/"+url.PathEscape(id)), nil)
+	if err != nil {
+		return &fs.PathError{Op: "revoke", Path: name, Err: err}
+	}
+	if err := f.doRequest(f.ctx, req, nil); err != nil {
+		return &fs.PathError{Op: "revoke", Path: name, Err: err}
+	}
+	return nil
+}
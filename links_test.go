@@ -0,0 +1,83 @@
+package onedrivefs
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"go.dataddo.com/onedrivefs/onedrivefstest"
+)
+
+func TestFS_Links(t *testing.T) {
+	fsys, _ := initFakeFileSystem(t, onedrivefstest.Options{})
+
+	view, err := fsys.CreateLink("README.md", LinkOptions{Type: LinkView, Scope: LinkAnonymous})
+	noErr(t, err)
+	assertEqual(t, LinkView, view.Type, "type")
+	assertEqual(t, LinkAnonymous, view.Scope, "scope")
+	assertEqual(t, []string{"read"}, view.Roles, "roles")
+	if view.URL == "" {
+		t.Error("the link has no URL")
+	}
+	again, err := fsys.CreateLink("README.md", LinkOptions{Type: LinkView, Scope: LinkAnonymous})
+	noErr(t, err)
+	assertEqual(t, view.ID, again.ID, "reused link")
+
+	expiration := time.Now().Add(24 * time.Hour).Truncate(time.Second).UTC()
+	edit, err := fsys.CreateLink("README.md", LinkOptions{Type: LinkEdit, Scope: LinkOrganization, Expiration: expiration, Password: "secret"})
+	noErr(t, err)
+	assertEqual(t, []string{"write"}, edit.Roles, "roles")
+	assertEqual(t, true, edit.HasPassword, "password")
+	assertEqual(t, true, expiration.Equal(edit.Expiration), "expiration")
+
+	links, err := fsys.Links("README.md")
+	noErr(t, err)
+	assertEqual(t, 2, len(links), "links")
+
+	dirLink, err := fsys.CreateLink("subdir1", LinkOptions{Type: LinkView})
+	noErr(t, err)
+	dir, err := fsys.Stat("subdir1")
+	noErr(t, err)
+	links, err = fsys.Links("subdir1/subdir2/foo.json")
+	noErr(t, err)
+	assertEqual(t, 1, len(links), "inherited links")
+	assertEqual(t, dirLink.ID, links[0].ID, "inherited link")
+	assertEqual(t, dir.Sys().(*Item).ID, links[0].InheritedFrom.ID, "inherited from")
+
+	noErr(t, fsys.RevokeLink("README.md", view.ID))
+	links, err = fsys.Links("README.md")
+	noErr(t, err)
+	assertEqual(t, 1, len(links), "links")
+	assertEqual(t, edit.ID, links[0].ID, "remaining link")
+	if err := fsys.RevokeLink("README.md", view.ID); !errors.Is(err, fs.ErrNotExist) {
+		t.Error("expected fs.ErrNotExist, got:", err)
+	}
+	if _, err := fsys.Links("missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Error("expected fs.ErrNotExist, got:", err)
+	}
+}
+
+func TestFS_CreateLink_policy(t *testing.T) {
+	fsys, srv := initFakeFileSystem(t, onedrivefstest.Options{DisableAnonymousLinks: true})
+
+	_, err := fsys.CreateLink("README.md", LinkOptions{Type: LinkView, Scope: LinkAnonymous})
+	var apiErr *OneDriveAPIError
+	if !errors.As(err, &apiErr) || apiErr.Code != NotAllowedErrorCode {
+		t.Error("expected notAllowed error, got:", err)
+	}
+	_, err = fsys.CreateLink("README.md", LinkOptions{Type: LinkView, Scope: LinkOrganization})
+	noErr(t, err)
+
+	srv.InjectFault(onedrivefstest.Fault{
+		Match:      func(r *http.Request) bool { return strings.HasSuffix(r.URL.Path, "/createLink") },
+		StatusCode: http.StatusForbidden,
+		Code:       AccessDeniedErrorCode,
+	})
+	_, err = fsys.CreateLink("README.md", LinkOptions{Type: LinkEdit, Scope: LinkOrganization})
+	if !errors.As(err, &apiErr) || apiErr.Code != AccessDeniedErrorCode || !errors.Is(err, fs.ErrPermission) {
+		t.Error("expected accessDenied error, got:", err)
+	}
+}
@@ -1,51 +1,221 @@
 package onedrivefs
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
+	"io"
 	"net/http"
 	"net/url"
+	"path"
+	"strings"
 	"time"
 )
 
 // getDriveItemsByPath is an extension to
-// (*onedrive.DriveItemsService).GetByPath allowing to get items from a specific
-// drive of the authenticated user.
+// (*onedrive.DriveItemsService).GetByPath allowing to get items from the drive
+// configured by DriveOpts.Drive. The items are cached when DriveOpts.Cache is
+// set.
 //
 // OneDrive API docs: https://docs.microsoft.com/en-us/onedrive/developer/rest-api/api/driveitem_get
-func getDriveItemsByPath(ctx context.Context, client *http.Client, driveID, itemPath string) (*driveItem, error) {
-	apiURL := "me/drive/root"
-	if driveID != "" {
-		apiURL = "/v1.0/drives/" + url.PathEscape(driveID) + "/root"
+func (f *FS) getDriveItemsByPath(ctx context.Context, itemPath string) (*driveItem, error) {
+	apiURL := f.itemPathURL(itemPath, "")
+	if f.cache == nil {
+		return f.getDriveItem(ctx, apiURL, "")
 	}
-	if itemPath != "" {
-		apiURL += ":/" + url.PathEscape(itemPath)
+	key := itemCacheKey(apiURL)
+	entry, cached := f.cache.get(key)
+	if cached && f.cache.fresh(entry) {
+		return entry.item, nil
+	}
+	if !cached {
+		if item, ok, err := f.cachedChild(itemPath, key); ok {
+			return item, err
+		}
+	}
+	eTag := ""
+	if cached && f.cache.revalidatable(entry) {
+		eTag = entry.item.ETag
+	}
+	item, err := f.getDriveItem(ctx, apiURL, eTag)
+	if errors.Is(err, errNotModified) {
+		f.cache.renew(entry)
+		return entry.item, nil
 	}
-	req, err := newRequest("GET", apiURL)
 	if err != nil {
 		return nil, err
 	}
+	now := time.Now()
+	f.cache.put(&cacheEntry{key: key, item: item, fetched: now, validated: now})
+	return item, nil
+}
+
+// getFreshDriveItemByPath gets the item at the path bypassing the cache. The
+// changes like Remove and Rename decide on it, so it must not be stale.
+func (f *FS) getFreshDriveItemByPath(ctx context.Context, itemPath string) (*driveItem, error) {
+	return f.getDriveItem(ctx, f.itemPathURL(itemPath, ""), "")
+}
+
+// cachedChild looks the item up in the cached listing of its parent folder. It
+// reports false when the listing is not cached.
+func (f *FS) cachedChild(itemPath, key string) (*driveItem, bool, error) {
+	if itemPath == "" {
+		return nil, false, nil
+	}
+	parentPath, name := path.Split(itemPath)
+	parent, ok := f.cache.getFresh(itemCacheKey(f.itemPathURL(strings.TrimSuffix(parentPath, "/"), "")))
+	if !ok {
+		return nil, false, nil
+	}
+	listing, ok := f.cache.getFresh(childrenCacheKey(parent.item.ID))
+	if !ok {
+		return nil, false, nil
+	}
+	for _, child := range listing.children {
+		// OneDrive finds the names case-insensitively.
+		if strings.EqualFold(child.Name, name) {
+			f.cache.put(&cacheEntry{key: key, item: child, fetched: listing.fetched, validated: listing.validated})
+			return child, true, nil
+		}
+	}
+	return nil, true, &OneDriveAPIError{Code: ItemNotFoundErrorCode, Message: "The resource could not be found."}
+}
+
+// cachedChildren returns the cached listing of the folder, revalidating the
+// expired one. It reports false when there is no usable listing.
+func (f *FS) cachedChildren(ctx context.Context, folderID string) ([]*driveItem, bool) {
+	entry, ok := f.cache.get(childrenCacheKey(folderID))
+	if !ok {
+		return nil, false
+	}
+	if f.cache.fresh(entry) {
+		return entry.children, true
+	}
+	if !f.cache.revalidatable(entry) {
+		return nil, false
+	}
+	// The eTag of a folder changes with its children.
+	if _, err := f.getDriveItem(ctx, f.itemIDURL(folderID, ""), entry.item.ETag); !errors.Is(err, errNotModified) {
+		return nil, false
+	}
+	f.cache.renew(entry)
+	return entry.children, true
+}
+
+// cacheChildren caches the listing of the folder. The folder eTag must
+// precede the listing.
+func (f *FS) cacheChildren(folderID, eTag string, children []*driveItem, fetched time.Time) {
+	if f.cache == nil {
+		return
+	}
+	f.cache.put(&cacheEntry{
+		key:       childrenCacheKey(folderID),
+		item:      &driveItem{ID: folderID, ETag: eTag},
+		children:  children,
+		fetched:   fetched,
+		validated: fetched,
+	})
+}
+
+// invalidateCache removes the cached metadata made stale by a change of the
+// named item: the item with everything under it, and its parent folder with
+// its listing. The changed items known to the caller are removed with their
+// parent folders also when they are cached under other paths, e.g. by Sub.
+func (f *FS) invalidateCache(name string, items ...*driveItem) {
+	if f.cache == nil {
+		return
+	}
+	parentPath := path.Dir(name)
+	if parentPath == "." {
+		parentPath = ""
+	}
+	itemKey := itemCacheKey(f.itemPathURL(name, ""))
+	parentKey := itemCacheKey(f.itemPathURL(parentPath, ""))
+	ids := map[string]bool{}
+	if entry, ok := f.cache.get(itemKey); ok {
+		items = append(items, entry.item)
+	}
+	if entry, ok := f.cache.get(parentKey); ok {
+		ids[entry.item.ID] = true
+	}
+	// OneDrive finds the names case-insensitively.
+	itemKey, parentKey = strings.ToLower(itemKey), strings.ToLower(parentKey)
+	for _, item := range items {
+		if item == nil {
+			continue
+		}
+		ids[item.ID] = true
+		if item.ParentReference != nil && item.ParentReference.ID != "" {
+			ids[item.ParentReference.ID] = true
+		}
+	}
+	f.cache.removeFunc(func(entry *cacheEntry) bool {
+		// The listings are cached with the folders as their items.
+		if ids[entry.item.ID] {
+			return true
+		}
+		key := strings.ToLower(entry.key)
+		return key == itemKey || key == parentKey || strings.HasPrefix(key, itemKey+"/")
+	})
+}
+
+// getDriveItem gets the item of the API URL. With eTag, it fails with
+// errNotModified when the item still has it.
+func (f *FS) getDriveItem(ctx context.Context, apiURL, eTag string) (*driveItem, error) {
+	req, err := f.newRequest("GET", apiURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if eTag != "" {
+		req.Header.Set("If-None-Match", eTag)
+	}
 	var driveItem *driveItem
-	if err := doRequest(ctx, client, req, &driveItem); err != nil {
+	if err := f.doRequest(ctx, req, &driveItem); err != nil {
 		return nil, err
 	}
 	return driveItem, nil
 }
 
+// getDriveItemByID gets the item of the ID.
+//
+// OneDrive API docs: https://learn.microsoft.com/en-us/graph/api/driveitem-get
+func (f *FS) getDriveItemByID(ctx context.Context, itemID string) (*driveItem, error) {
+	return f.getDriveItem(ctx, f.itemIDURL(itemID, ""), "")
+}
+
 // driveItem represents a OneDrive drive item.
 // Ref https://docs.microsoft.com/en-us/graph/api/resources/driveitem?view=graph-rest-1.0
 // It's an extended version of onedrive.DriveItem.
 type driveItem struct {
 	ID                   string         `json:"id"`
 	Name                 string         `json:"name"`
+	ETag                 string         `json:"eTag"`
+	CTag                 string         `json:"cTag"`
+	WebURL               string         `json:"webUrl"`
 	DownloadURL          string         `json:"@microsoft.graph.downloadUrl"`
 	Description          string         `json:"description"`
-	Folder               *struct{}      `json:"folder"`
+	Folder               *folderFacet   `json:"folder"`
+	File                 *fileFacet     `json:"file"`
 	Root                 *struct{}      `json:"root"`
 	Size                 int64          `json:"size"`
 	CreatedDateTime      dateTimeOffset `json:"createdDateTime"`
 	LastModifiedDateTime dateTimeOffset `json:"lastModifiedDateTime"`
+	CreatedBy            IdentitySet    `json:"createdBy"`
+	LastModifiedBy       IdentitySet    `json:"lastModifiedBy"`
+	ParentReference      *ItemReference `json:"parentReference"`
+	Deleted              *struct{}      `json:"deleted"`
+	// Thumbnails are set when requested by $expand, see thumbnailsQuery.
+	Thumbnails []thumbnailSet `json:"thumbnails"`
+}
+
+type folderFacet struct {
+	ChildCount int `json:"childCount"`
+}
+
+type fileFacet struct {
+	MimeType string `json:"mimeType"`
+	Hashes   Hashes `json:"hashes"`
 }
 
 type dateTimeOffset time.Time
@@ -59,22 +229,16 @@ func (d *dateTimeOffset) UnmarshalText(text []byte) error {
 	return nil
 }
 
-// listDriveItems lists the items of a folder of the authenticated user. It's an
-// extension to (*onedrive.DriveItemsService).List method.
+// listDriveItems lists the items of a folder, the root folder when folderID is
+// empty. It's an extension to (*onedrive.DriveItemsService).List method.
 //
 // OneDrive API docs: https://docs.microsoft.com/en-us/onedrive/developer/rest-api/resources/driveitem?view=odsp-graph-online
-func listDriveItems(ctx context.Context, client *http.Client, driveID, folderID string) (*driveItemsResponse, error) {
-	apiURL := "me/drive/root/children"
+func (f *FS) listDriveItems(ctx context.Context, folderID string) (*driveItemsResponse, error) {
+	apiURL := f.itemPathURL("", "children")
 	if folderID != "" {
-		apiURL = "me/drive/items/" + url.PathEscape(folderID) + "/children"
+		apiURL = f.itemIDURL(folderID, "children")
 	}
-	if driveID != "" {
-		apiURL = "me/drives/" + url.PathEscape(driveID) + "/root/children"
-		if folderID != "" {
-			apiURL = "me/drives/" + url.PathEscape(driveID) + "/items/" + url.PathEscape(folderID) + "/children"
-		}
-	}
-	req, err := newRequest("GET", apiURL)
+	req, err := f.newRequest("GET", apiURL, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -82,56 +246,245 @@ func listDriveItems(ctx context.Context, client *http.Client, driveID, folderID
 		"$orderby": {"name asc"},
 	}.Encode()
 	var oneDriveResponse *driveItemsResponse
-	if err := doRequest(ctx, client, req, &oneDriveResponse); err != nil {
+	if err := f.doRequest(ctx, req, &oneDriveResponse); err != nil {
+		return nil, err
+	}
+	return oneDriveResponse, nil
+}
+
+// listDriveItemsByPath lists the items of the folder at folderPath whose names
+// start with the prefix, case-insensitively. All the items are listed when
+// the prefix is empty.
+//
+// OneDrive API docs: https://learn.microsoft.com/en-us/graph/filter-query-parameter
+func (f *FS) listDriveItemsByPath(ctx context.Context, folderPath, prefix string) (*driveItemsResponse, error) {
+	req, err := f.newRequest("GET", f.itemPathURL(folderPath, "children"), nil)
+	if err != nil {
+		return nil, err
+	}
+	if prefix != "" {
+		req.URL.RawQuery = url.Values{
+			"$filter": {"startswith(name,'" + strings.ReplaceAll(prefix, "'", "''") + "')"},
+		}.Encode()
+	}
+	var oneDriveResponse *driveItemsResponse
+	if err := f.doRequest(ctx, req, &oneDriveResponse); err != nil {
+		return nil, err
+	}
+	return oneDriveResponse, nil
+}
+
+// listDriveItemsNextPage gets the next page of a folder listing started by
+// listDriveItems.
+//
+// OneDrive API docs: https://learn.microsoft.com/en-us/graph/paging
+func (f *FS) listDriveItemsNextPage(ctx context.Context, nextLink string) (*driveItemsResponse, error) {
+	req, err := f.newRequest("GET", nextLink, nil)
+	if err != nil {
+		return nil, err
+	}
+	var oneDriveResponse *driveItemsResponse
+	if err := f.doRequest(ctx, req, &oneDriveResponse); err != nil {
 		return nil, err
 	}
 	return oneDriveResponse, nil
 }
 
+// uploadDriveItem uploads the content of a file at itemPath. It's suitable
+// for files up to 250 MB.
+//
+// OneDrive API docs: https://learn.microsoft.com/en-us/graph/api/driveitem-put-content
+func (f *FS) uploadDriveItem(ctx context.Context, itemPath string, content []byte, conflictBehavior string) (*driveItem, error) {
+	req, err := f.newRequest("PUT", f.itemPathURL(itemPath, "content"), bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+	req.URL.RawQuery = url.Values{
+		"@microsoft.graph.conflictBehavior": {conflictBehavior},
+	}.Encode()
+	req.Header.Set("Content-Type", "application/octet-stream")
+	var driveItem *driveItem
+	if err := f.doRequest(ctx, req, &driveItem); err != nil {
+		return nil, err
+	}
+	return driveItem, nil
+}
+
+// createFolder creates a folder in the parent folder. It fails when an item
+// of the same name exists.
+//
+// OneDrive API docs: https://learn.microsoft.com/en-us/graph/api/driveitem-post-children
+func (f *FS) createFolder(ctx context.Context, parentID, name string) (*driveItem, error) {
+	req, err := f.newJSONRequest("POST", f.itemIDURL(parentID, "children"), struct {
+		Name             string   `json:"name"`
+		Folder           struct{} `json:"folder"`
+		ConflictBehavior string   `json:"@microsoft.graph.conflictBehavior"`
+	}{
+		Name:             name,
+		ConflictBehavior: "fail",
+	})
+	if err != nil {
+		return nil, err
+	}
+	var driveItem *driveItem
+	if err := f.doRequest(ctx, req, &driveItem); err != nil {
+		return nil, err
+	}
+	return driveItem, nil
+}
+
+// deleteDriveItem deletes the item, including the content of folders.
+//
+// OneDrive API docs: https://learn.microsoft.com/en-us/graph/api/driveitem-delete
+func (f *FS) deleteDriveItem(ctx context.Context, itemID string) error {
+	req, err := f.newRequest("DELETE", f.itemIDURL(itemID, ""), nil)
+	if err != nil {
+		return err
+	}
+	return f.doRequest(ctx, req, nil)
+}
+
+// moveDriveItem renames the item and moves it to the parent folder. An
+// existing item of the same name is replaced when replace is set.
+//
+// OneDrive API docs: https://learn.microsoft.com/en-us/graph/api/driveitem-move
+func (f *FS) moveDriveItem(ctx context.Context, itemID, parentID, name string, replace bool) (*driveItem, error) {
+	req, err := f.newJSONRequest("PATCH", f.itemIDURL(itemID, ""), struct {
+		Name            string         `json:"name"`
+		ParentReference *ItemReference `json:"parentReference,omitempty"`
+	}{
+		Name:            name,
+		ParentReference: &ItemReference{ID: parentID},
+	})
+	if err != nil {
+		return nil, err
+	}
+	conflictBehavior := "fail"
+	if replace {
+		conflictBehavior = "replace"
+	}
+	req.URL.RawQuery = url.Values{
+		"@microsoft.graph.conflictBehavior": {conflictBehavior},
+	}.Encode()
+	var driveItem *driveItem
+	if err := f.doRequest(ctx, req, &driveItem); err != nil {
+		return nil, err
+	}
+	return driveItem, nil
+}
+
+// itemPathURL returns the API URL of the item at itemPath, relative to the
+// root of the FS, optionally followed by an action like "children" or
+// "content".
+func (f *FS) itemPathURL(itemPath, action string) string {
+	apiURL := f.opts.Drive.apiPath() + "/root"
+	if f.rootID != "" {
+		apiURL = f.itemIDURL(f.rootID, "")
+	}
+	if itemPath != "" {
+		apiURL += ":/" + escapePath(itemPath)
+		if action != "" {
+			apiURL += ":"
+		}
+	}
+	if action != "" {
+		apiURL += "/" + action
+	}
+	return apiURL
+}
+
+// itemIDURL returns the API URL of the item, optionally followed by an action
+// like "children" or "content".
+func (f *FS) itemIDURL(itemID, action string) string {
+	apiURL := f.opts.Drive.apiPath() + "/items/" + url.PathEscape(itemID)
+	if action != "" {
+		apiURL += "/" + action
+	}
+	return apiURL
+}
+
+// escapePath escapes the elements of a slash separated path.
+func escapePath(itemPath string) string {
+	elems := strings.Split(itemPath, "/")
+	for i, elem := range elems {
+		elems[i] = url.PathEscape(elem)
+	}
+	return strings.Join(elems, "/")
+}
+
 // driveItemsResponse represents the JSON object returned by the OneDrive API.
 // It's an extended version of onedrive.OneDriveDriveItemsResponse.
 type driveItemsResponse struct {
 	ODataContext string       `json:"@odata.context"`
 	Count        int          `json:"@odata.count"`
+	NextLink     string       `json:"@odata.nextLink"`
+	DeltaLink    string       `json:"@odata.deltaLink"`
 	DriveItems   []*driveItem `json:"value"`
 }
 
-var baseURL = url.URL{
-	Scheme: "https",
-	Host:   "graph.microsoft.com",
-	Path:   "/v1.0/",
+func (f *FS) newRequest(method, relativeURL string, body io.Reader) (*http.Request, error) {
+	apiURL, err := f.endpoint.Parse(relativeURL)
+	if err != nil {
+		return nil, err
+	}
+	return http.NewRequest(method, apiURL.String(), body)
 }
 
-func newRequest(method, relativeURL string) (*http.Request, error) {
-	apiURL, err := baseURL.Parse(relativeURL)
+func (f *FS) newJSONRequest(method, relativeURL string, payload any) (*http.Request, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	req, err := f.newRequest(method, relativeURL, bytes.NewReader(body))
 	if err != nil {
 		return nil, err
 	}
-	return http.NewRequest(method, apiURL.String(), nil)
+	req.Header.Set("Content-Type", "application/json")
+	return req, nil
+}
+
+func (f *FS) doRequest(ctx context.Context, req *http.Request, target interface{}) error {
+	_, err := f.doRequestHeader(ctx, req, target)
+	return err
 }
 
-func doRequest(ctx context.Context, client *http.Client, req *http.Request, target interface{}) error {
-	resp, err := client.Do(req.WithContext(ctx))
+// doRequestHeader is like doRequest, but it returns the response header too.
+func (f *FS) doRequestHeader(ctx context.Context, req *http.Request, target interface{}) (http.Header, error) {
+	resp, err := f.retry.do(ctx, f.client, req)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer func() { _ = resp.Body.Close() }()
 
+	if resp.StatusCode == http.StatusNotModified {
+		return resp.Header, errNotModified
+	}
 	if resp.StatusCode >= 400 {
-		var oneDriveError struct {
-			Error *OneDriveAPIError `json:"error"`
-		}
-		if err := json.NewDecoder(resp.Body).Decode(&oneDriveError); err != nil {
-			return errors.New("unexpected error: " + resp.Status)
-		}
-		if oneDriveError.Error != nil {
-			oneDriveError.Error.ResponseHeader = resp.Header
-			return oneDriveError.Error
-		}
-		return errors.New("unexpected error: " + resp.Status)
+		return nil, responseError(resp)
 	}
-	if resp.StatusCode != 204 {
+	if resp.StatusCode != 204 && target != nil {
 		err = json.NewDecoder(resp.Body).Decode(target)
 	}
-	return err
+	return resp.Header, err
+}
+
+// errNotModified is returned by conditional requests for unchanged resources.
+var errNotModified = errors.New("not modified")
+
+// responseError returns the error described by the body of a failed response,
+// or an HTTPError when the body doesn't describe it.
+func responseError(resp *http.Response) error {
+	var oneDriveError struct {
+		Error *OneDriveAPIError `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&oneDriveError); err == nil && oneDriveError.Error != nil {
+		oneDriveError.Error.StatusCode = resp.StatusCode
+		oneDriveError.Error.ResponseHeader = resp.Header
+		return oneDriveError.Error
+	}
+	return &HTTPError{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Header:     resp.Header,
+	}
 }
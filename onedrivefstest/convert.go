@@ -0,0 +1,33 @@
+package onedrivefstest
+
+import (
+	"html"
+	"path"
+	"slices"
+	"strings"
+)
+
+// convertibleExtensions are the extensions of the files converted to the
+// formats, a subset of the ones OneDrive converts.
+var convertibleExtensions = map[string][]string{
+	"pdf":  {".doc", ".docx", ".md", ".odp", ".ods", ".odt", ".ppt", ".pptx", ".rtf", ".xls", ".xlsx"},
+	"html": {".fluid", ".loop", ".wiki"},
+	"jpg":  {".3mf", ".glb", ".gltf", ".obj", ".stl"},
+}
+
+func convertible(name, format string) bool {
+	return slices.Contains(convertibleExtensions[format], strings.ToLower(path.Ext(name)))
+}
+
+// convert fakes the conversion of the content to the format, wrapping it in
+// a recognizable header.
+func convert(content []byte, format string) []byte {
+	switch format {
+	case "pdf":
+		return append([]byte("%PDF-1.7 fake\n"), content...)
+	case "html":
+		return []byte("<html><body>" + html.EscapeString(string(content)) + "</body></html>")
+	default:
+		return append([]byte("\xff\xd8\xff fake "+format+"\n"), content...)
+	}
+}
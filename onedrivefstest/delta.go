@@ -0,0 +1,141 @@
+package onedrivefstest
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// tombstone records a deleted item for the delta queries.
+type tombstone struct {
+	id       string
+	name     string
+	parentID string
+	path     string
+	dir      bool
+	seq      int
+}
+
+// ExpireDeltaTokens invalidates the delta tokens issued so far, so the delta
+// queries using them fail with resyncRequired, like when OneDrive discards the
+// old change history.
+func (s *Server) ExpireDeltaTokens() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deltaEpoch++
+}
+
+// serveDelta serves the changes of the items in the folder since the state
+// identified by the token query parameter. The caller must hold the lock.
+func (s *Server) serveDelta(w http.ResponseWriter, r *http.Request, dir *item) {
+	if !dir.isDir() {
+		writeError(w, http.StatusBadRequest, "invalidRequest", "The item is not a folder.")
+		return
+	}
+	query := r.URL.Query()
+	since := 0
+	switch token := query.Get("token"); token {
+	case "":
+	case "latest":
+		writeJSON(w, http.StatusOK, listResponse{
+			Value:     []*itemResource{},
+			DeltaLink: s.deltaLink(r, s.seq),
+		})
+		return
+	default:
+		epoch, seq, ok := parseDeltaToken(token)
+		if !ok {
+			writeError(w, http.StatusBadRequest, "invalidRequest", "Invalid delta token.")
+			return
+		}
+		if epoch != s.deltaEpoch {
+			writeError(w, http.StatusGone, "resyncRequired", "The delta token is no longer valid.")
+			return
+		}
+		since = seq
+	}
+	// The pages of a query show the changes up to the state of the first
+	// page, the later changes are left to the next query.
+	until := s.seq
+	if u, err := strconv.Atoi(query.Get("until")); err == nil {
+		until = u
+	}
+	changes := s.deltaChanges(dir, since, until)
+	skip, _ := strconv.Atoi(query.Get("$skiptoken"))
+	skip = min(skip, len(changes))
+	end := min(skip+s.opts.PageSize, len(changes))
+
+	page := listResponse{Value: changes[skip:end]}
+	if end < len(changes) {
+		query.Set("until", strconv.Itoa(until))
+		query.Set("$skiptoken", strconv.Itoa(end))
+		page.NextLink = s.srv.URL + r.URL.EscapedPath() + "?" + query.Encode()
+	} else {
+		page.DeltaLink = s.deltaLink(r, until)
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+// deltaChanges returns the items of the folder changed after the change since
+// up to the change until, the parents before their children, followed by the
+// deleted items. The caller must hold the lock.
+func (s *Server) deltaChanges(dir *item, since, until int) []*itemResource {
+	changes := []*itemResource{}
+	var walk func(it *item)
+	walk = func(it *item) {
+		if it.changed > since && it.changed <= until {
+			res := s.resource(it)
+			// Like OneDrive, the delta responses don't include the paths.
+			if res.ParentReference != nil {
+				res.ParentReference.Path = ""
+			}
+			changes = append(changes, res)
+		}
+		for _, child := range it.sortedChildren() {
+			walk(child)
+		}
+	}
+	walk(dir)
+
+	dirPath := dir.path()
+	for _, t := range s.tombstones {
+		if t.seq <= since || t.seq > until {
+			continue
+		}
+		if dirPath != "" && !strings.HasPrefix(t.path, dirPath+"/") {
+			continue
+		}
+		res := &itemResource{
+			ID:              t.id,
+			Name:            t.name,
+			Deleted:         &deletedFacet{State: "deleted"},
+			ParentReference: &itemReference{DriveID: DriveID, ID: t.parentID},
+		}
+		if t.dir {
+			res.Folder = &folderFacet{}
+		} else {
+			res.File = &fileFacet{MimeType: mimeType(t.name)}
+		}
+		changes = append(changes, res)
+	}
+	return changes
+}
+
+// deltaLink returns the link to the next delta query continuing after the
+// change seq. The caller must hold the lock.
+func (s *Server) deltaLink(r *http.Request, seq int) string {
+	query := url.Values{"token": {fmt.Sprintf("%d.%d", s.deltaEpoch, seq)}}
+	return s.srv.URL + r.URL.EscapedPath() + "?" + query.Encode()
+}
+
+func parseDeltaToken(token string) (epoch, seq int, ok bool) {
+	e, q, ok := strings.Cut(token, ".")
+	if !ok {
+		return 0, 0, false
+	}
+	epoch, err1 := strconv.Atoi(e)
+	seq, err2 := strconv.Atoi(q)
+	return epoch, seq, err1 == nil && err2 == nil
+}
@@ -0,0 +1,139 @@
+package onedrivefstest
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// sharingLink is a sharing link of an item.
+type sharingLink struct {
+	id          string
+	typ         string
+	scope       string
+	expiration  time.Time
+	hasPassword bool
+}
+
+// permissionResource is the JSON representation of a sharing link.
+type permissionResource struct {
+	ID                 string         `json:"id"`
+	Roles              []string       `json:"roles"`
+	Link               linkFacet      `json:"link"`
+	ExpirationDateTime *time.Time     `json:"expirationDateTime,omitempty"`
+	HasPassword        bool           `json:"hasPassword"`
+	InheritedFrom      *itemReference `json:"inheritedFrom,omitempty"`
+}
+
+type linkFacet struct {
+	Type   string `json:"type"`
+	Scope  string `json:"scope"`
+	WebURL string `json:"webUrl"`
+}
+
+func (s *Server) permissionResource(link *sharingLink) *permissionResource {
+	res := &permissionResource{
+		ID:          link.id,
+		Roles:       []string{"read"},
+		Link:        linkFacet{Type: link.typ, Scope: link.scope, WebURL: s.srv.URL + "/share/" + link.id},
+		HasPassword: link.hasPassword,
+	}
+	if link.typ == "edit" {
+		res.Roles = []string{"write"}
+	}
+	if !link.expiration.IsZero() {
+		res.ExpirationDateTime = &link.expiration
+	}
+	return res
+}
+
+// serveCreateLink serves the createLink action. The links without a password
+// and an expiration are reused for the same type and scope. The caller must
+// hold the lock.
+func (s *Server) serveCreateLink(w http.ResponseWriter, r *http.Request, it *item) {
+	var payload struct {
+		Type               string    `json:"type"`
+		Scope              string    `json:"scope"`
+		ExpirationDateTime time.Time `json:"expirationDateTime"`
+		Password           string    `json:"password"`
+	}
+	if err := decodeJSON(r, &payload); err != nil {
+		writeError(w, http.StatusBadRequest, "invalidRequest", err.Error())
+		return
+	}
+	if payload.Scope == "" {
+		payload.Scope = "organization"
+	}
+	switch {
+	case !slices.Contains([]string{"view", "edit", "embed"}, payload.Type):
+		writeError(w, http.StatusBadRequest, "invalidRequest", "Invalid link type "+payload.Type+".")
+		return
+	case !slices.Contains([]string{"anonymous", "organization", "users"}, payload.Scope):
+		writeError(w, http.StatusBadRequest, "invalidRequest", "Invalid link scope "+payload.Scope+".")
+		return
+	case !payload.ExpirationDateTime.IsZero() && !payload.ExpirationDateTime.After(s.now()):
+		writeError(w, http.StatusBadRequest, "invalidRequest", "The expiration must be in the future.")
+		return
+	case payload.Scope == "anonymous" && s.opts.DisableAnonymousLinks:
+		writeError(w, http.StatusForbidden, "notAllowed", "Anonymous links are disabled by the tenant policy.")
+		return
+	}
+	reusable := payload.ExpirationDateTime.IsZero() && payload.Password == ""
+	if reusable {
+		for _, link := range it.links {
+			if link.typ == payload.Type && link.scope == payload.Scope && link.expiration.IsZero() && !link.hasPassword {
+				writeJSON(w, http.StatusOK, s.permissionResource(link))
+				return
+			}
+		}
+	}
+	s.lastID++
+	link := &sharingLink{
+		id:          fmt.Sprintf("LINK%d", s.lastID),
+		typ:         payload.Type,
+		scope:       payload.Scope,
+		expiration:  payload.ExpirationDateTime.UTC(),
+		hasPassword: payload.Password != "",
+	}
+	it.links = append(it.links, link)
+	writeJSON(w, http.StatusCreated, s.permissionResource(link))
+}
+
+// servePermissions serves the "permissions" listing, with the links of the
+// ancestors inherited, and the deletion of "permissions/{id}". The caller
+// must hold the lock.
+func (s *Server) servePermissions(w http.ResponseWriter, r *http.Request, it *item, action string) {
+	switch escapedID, ok := strings.CutPrefix(action, "permissions/"); {
+	case r.Method == "GET" && action == "permissions":
+		value := []*permissionResource{}
+		for _, link := range it.links {
+			value = append(value, s.permissionResource(link))
+		}
+		for ancestor := it.parent; ancestor != nil; ancestor = ancestor.parent {
+			for _, link := range ancestor.links {
+				res := s.permissionResource(link)
+				res.InheritedFrom = &itemReference{DriveID: DriveID, ID: ancestor.id}
+				value = append(value, res)
+			}
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"value": value})
+	case r.Method == "DELETE" && ok:
+		id, err := url.PathUnescape(escapedID)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalidRequest", err.Error())
+			return
+		}
+		i := slices.IndexFunc(it.links, func(link *sharingLink) bool { return link.id == id })
+		if i < 0 {
+			writeError(w, http.StatusNotFound, "itemNotFound", "The permission could not be found.")
+			return
+		}
+		it.links = slices.Delete(it.links, i, i+1)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusBadRequest, "invalidRequest", "unsupported request "+r.Method+" "+action)
+	}
+}
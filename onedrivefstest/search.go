@@ -0,0 +1,68 @@
+package onedrivefstest
+
+import (
+	"bytes"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// serveSearch serves the search of the items in the folder by the action like
+// "search(q='text')". An item matches when its name or its content contains
+// the text, case-insensitively. The caller must hold the lock.
+func (s *Server) serveSearch(w http.ResponseWriter, r *http.Request, dir *item, action string) {
+	text, ok := parseSearchAction(action)
+	if !ok || !dir.isDir() {
+		writeError(w, http.StatusBadRequest, "invalidRequest", "Invalid search.")
+		return
+	}
+	text = strings.ToLower(text)
+	var found []*itemResource
+	var walk func(it *item)
+	walk = func(it *item) {
+		for _, child := range it.sortedChildren() {
+			if strings.Contains(strings.ToLower(child.name), text) ||
+				bytes.Contains(bytes.ToLower(child.content), []byte(text)) {
+				res := s.resource(child)
+				// Like OneDrive, the search results don't include the paths.
+				res.ParentReference.Path = ""
+				found = append(found, res)
+			}
+			walk(child)
+		}
+	}
+	walk(dir)
+
+	query := r.URL.Query()
+	pageSize := s.opts.PageSize
+	if top, err := strconv.Atoi(query.Get("$top")); err == nil && top > 0 && top < pageSize {
+		pageSize = top
+	}
+	skip, _ := strconv.Atoi(query.Get("$skiptoken"))
+	skip = min(skip, len(found))
+	end := min(skip+pageSize, len(found))
+	page := listResponse{Value: append([]*itemResource{}, found[skip:end]...)}
+	if end < len(found) {
+		query.Set("$skiptoken", strconv.Itoa(end))
+		page.NextLink = s.srv.URL + r.URL.EscapedPath() + "?" + query.Encode()
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+// parseSearchAction parses the escaped action like "search(q='text')".
+func parseSearchAction(action string) (text string, ok bool) {
+	action, err := url.PathUnescape(action)
+	if err != nil {
+		return "", false
+	}
+	quoted, ok := strings.CutPrefix(action, "search(q='")
+	if !ok {
+		return "", false
+	}
+	quoted, ok = strings.CutSuffix(quoted, "')")
+	if !ok {
+		return "", false
+	}
+	return strings.ReplaceAll(quoted, "''", "'"), true
+}
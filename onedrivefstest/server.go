@@ -0,0 +1,707 @@
+// Package onedrivefstest implements an in-memory fake of the Microsoft Graph
+// drive API, so code using onedrivefs can be tested without network access
+// and OneDrive credentials.
+//
+// Point the FS at the fake with its endpoint:
+//
+//	srv := onedrivefstest.NewServer(onedrivefstest.Options{})
+//	defer srv.Close()
+//	fsys, err := onedrivefs.OpenFS(srv.Client(), onedrivefs.DriveOpts{Endpoint: srv.Endpoint()})
+package onedrivefstest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DriveID is the ID of the drive served by the fake.
+const DriveID = "fake-drive"
+
+// Options configures the fake server.
+type Options struct {
+	// PageSize is the maximum number of items in a page of a listing, unless
+	// the client asks for less with $top. It defaults to 200.
+	PageSize int
+	// DisableFilter makes the server refuse the $filter query parameter of
+	// listings, like OneDrive does for some drives and filters.
+	DisableFilter bool
+	// DisableAnonymousLinks makes the server refuse to create the anonymous
+	// sharing links, like a tenant policy does.
+	DisableAnonymousLinks bool
+	// Now returns the current time used for the timestamps of the items and
+	// the Date header of the responses. It defaults to time.Now.
+	Now func() time.Time
+}
+
+// Server is a fake of the Microsoft Graph drive API serving a single drive
+// from memory. It's safe for concurrent use.
+type Server struct {
+	srv  *httptest.Server
+	opts Options
+
+	mu       sync.Mutex
+	root     *item
+	items    map[string]*item
+	lastID   int
+	sessions map[string]*uploadSession
+	faults   []*fault
+	requests []string
+
+	// seq numbers the changes of the drive for the delta queries.
+	seq        int
+	tombstones []*tombstone
+	// deltaEpoch is increased by ExpireDeltaTokens, invalidating the delta
+	// tokens of the previous epochs.
+	deltaEpoch int
+	// downloadEpoch is increased by ExpireDownloadURLs, invalidating the
+	// download URLs of the previous epochs.
+	downloadEpoch int
+}
+
+// NewServer starts a fake server with an empty drive. It must be closed with
+// Close.
+func NewServer(opts Options) *Server {
+	if opts.PageSize <= 0 {
+		opts.PageSize = 200
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	s := &Server{
+		opts:     opts,
+		items:    map[string]*item{},
+		sessions: map[string]*uploadSession{},
+	}
+	s.root = s.newItem(nil, "", true)
+	s.srv = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// Close shuts the server down.
+func (s *Server) Close() { s.srv.Close() }
+
+// Endpoint returns the base URL of the fake Graph API, to be used as
+// onedrivefs.DriveOpts.Endpoint.
+func (s *Server) Endpoint() string { return s.srv.URL + "/v1.0/" }
+
+// Client returns an HTTP client for the server. The fake doesn't check
+// authentication, so any client works.
+func (s *Server) Client() *http.Client { return s.srv.Client() }
+
+// Requests returns the requests served so far, formatted like
+// "GET /v1.0/me/drive/root".
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.requests...)
+}
+
+// ResetRequests clears the list returned by Requests.
+func (s *Server) ResetRequests() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = nil
+}
+
+// WriteFile creates or replaces the named file, creating the missing parent
+// directories.
+func (s *Server) WriteFile(name string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	dir, err := s.mkdirAll(s.root, path.Dir(name))
+	if err != nil {
+		return err
+	}
+	s.writeContent(dir, path.Base(name), bytes.Clone(data))
+	return nil
+}
+
+// MkdirAll creates the named directory with the missing parents.
+func (s *Server) MkdirAll(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.mkdirAll(s.root, name)
+	return err
+}
+
+// ReadFile returns the content of the named file.
+func (s *Server) ReadFile(name string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	it, ok := s.lookup(s.root, name)
+	if !ok {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrNotExist}
+	}
+	if it.isDir() {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrInvalid}
+	}
+	return bytes.Clone(it.content), nil
+}
+
+// RemoveAll removes the named file or directory with all its content.
+func (s *Server) RemoveAll(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	it, ok := s.lookup(s.root, name)
+	if !ok {
+		return nil
+	}
+	if it == s.root {
+		return &fs.PathError{Op: "removeall", Path: name, Err: fs.ErrInvalid}
+	}
+	s.deleteItem(it)
+	return nil
+}
+
+// CopyFS copies the files and directories of fsys, e.g. os.DirFS of a local
+// directory, to the root of the drive.
+func (s *Server) CopyFS(fsys fs.FS) error {
+	return fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return s.MkdirAll(name)
+		}
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		return s.WriteFile(name, data)
+	})
+}
+
+// Fault is an error returned by the server instead of handling a request.
+type Fault struct {
+	// Match selects the requests that fail. All requests fail when nil.
+	Match func(*http.Request) bool
+	// Count is the number of requests that fail. When zero, the fault lasts
+	// until the server is closed.
+	Count int
+	// StatusCode is the HTTP status of the response.
+	StatusCode int
+	// Code is the OneDrive API error code, e.g. "activityLimitReached".
+	Code string
+	// Message is the error message, the code is used when empty.
+	Message string
+	// RetryAfter is sent in the Retry-After header when not zero.
+	RetryAfter time.Duration
+	// Truncate, when positive, makes the matching downloads break the
+	// connection after sending this many bytes of the content, instead of
+	// failing with StatusCode.
+	Truncate int64
+}
+
+type fault struct {
+	Fault
+	remaining int
+}
+
+// InjectFault makes the matching requests fail.
+func (s *Server) InjectFault(f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = append(s.faults, &fault{Fault: f, remaining: f.Count})
+}
+
+// ClearFaults removes all the injected faults.
+func (s *Server) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = nil
+}
+
+// Throttle makes the next n requests fail with 429 Too Many Requests, asking
+// the client to retry after the given delay.
+func (s *Server) Throttle(n int, retryAfter time.Duration) {
+	s.InjectFault(Fault{
+		Count:      n,
+		StatusCode: http.StatusTooManyRequests,
+		Code:       "activityLimitReached",
+		RetryAfter: retryAfter,
+	})
+}
+
+// takeFault returns the first fault matching the request. The caller must
+// hold the lock.
+func (s *Server) takeFault(r *http.Request) *Fault {
+	for i, f := range s.faults {
+		if f.Match != nil && !f.Match(r) {
+			continue
+		}
+		if f.Count > 0 {
+			f.remaining--
+			if f.remaining <= 0 {
+				s.faults = append(s.faults[:i], s.faults[i+1:]...)
+			}
+		}
+		return &f.Fault
+	}
+	return nil
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests = append(s.requests, r.Method+" "+r.URL.RequestURI())
+	f := s.takeFault(r)
+	w.Header().Set("Date", s.now().Format(http.TimeFormat))
+	s.mu.Unlock()
+	if f != nil && f.Truncate > 0 {
+		w = &truncatingWriter{ResponseWriter: w, remaining: f.Truncate}
+		f = nil
+	}
+	if f != nil {
+		// Consume the request body, so the client isn't left sending it.
+		_, _ = io.Copy(io.Discard, r.Body)
+		if f.RetryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(f.RetryAfter.Round(time.Second)/time.Second)))
+		}
+		message := f.Message
+		if message == "" {
+			message = f.Code
+		}
+		writeError(w, f.StatusCode, f.Code, message)
+		return
+	}
+
+	escapedPath := r.URL.EscapedPath()
+	switch {
+	case strings.HasPrefix(escapedPath, "/v1.0/"):
+		s.serveAPI(w, r, strings.TrimPrefix(escapedPath, "/v1.0/"))
+	case strings.HasPrefix(escapedPath, "/download/"):
+		s.serveDownload(w, r, strings.TrimPrefix(escapedPath, "/download/"))
+	case strings.HasPrefix(escapedPath, "/thumbnail/"):
+		s.serveThumbnailContent(w, r, strings.TrimPrefix(escapedPath, "/thumbnail/"))
+	case strings.HasPrefix(escapedPath, "/upload/"):
+		s.serveUploadSession(w, r, strings.TrimPrefix(escapedPath, "/upload/"))
+	default:
+		writeError(w, http.StatusNotFound, "itemNotFound", "unknown resource")
+	}
+}
+
+// serveAPI serves the drive API paths like "me/drive/root:/a/b:/children".
+func (s *Server) serveAPI(w http.ResponseWriter, r *http.Request, apiPath string) {
+	rest, ok := trimDrive(apiPath)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "invalidRequest", "unknown drive")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	base, relPath, action, err := s.parseItemPath(rest)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalidRequest", err.Error())
+		return
+	}
+	if base == nil {
+		writeError(w, http.StatusNotFound, "itemNotFound", "The resource could not be found.")
+		return
+	}
+	if r.Method == "PUT" && action == "content" {
+		s.serveUpload(w, r, base, relPath)
+		return
+	}
+	if r.Method == "POST" && action == "createUploadSession" {
+		s.serveCreateUploadSession(w, r, base, relPath)
+		return
+	}
+	it, ok := s.lookup(base, relPath)
+	if !ok {
+		writeError(w, http.StatusNotFound, "itemNotFound", "The resource could not be found.")
+		return
+	}
+	switch {
+	case r.Method == "GET" && action == "":
+		res := s.resource(it)
+		if r.Header.Get("If-None-Match") == res.ETag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		s.expandThumbnails(r, res, it)
+		writeJSON(w, http.StatusOK, res)
+	case r.Method == "PATCH" && action == "":
+		s.serveUpdate(w, r, it)
+	case r.Method == "DELETE" && action == "":
+		if it == s.root {
+			writeError(w, http.StatusForbidden, "notAllowed", "The root can't be deleted.")
+			return
+		}
+		s.deleteItem(it)
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == "GET" && action == "children":
+		s.serveChildren(w, r, it)
+	case r.Method == "GET" && action == "delta":
+		s.serveDelta(w, r, it)
+	case r.Method == "GET" && strings.HasPrefix(action, "search("):
+		s.serveSearch(w, r, it, action)
+	case r.Method == "POST" && action == "children":
+		s.serveCreateFolder(w, r, it)
+	case r.Method == "POST" && action == "createLink":
+		s.serveCreateLink(w, r, it)
+	case action == "permissions" || strings.HasPrefix(action, "permissions/"):
+		s.servePermissions(w, r, it, action)
+	case strings.HasPrefix(action, "thumbnails/"):
+		s.serveThumbnail(w, r, it, action)
+	case action == "versions" || strings.HasPrefix(action, "versions/"):
+		s.serveVersions(w, r, it, action)
+	case r.Method == "GET" && action == "content":
+		if it.isDir() {
+			writeError(w, http.StatusBadRequest, "invalidRequest", "Folders have no content.")
+			return
+		}
+		downloadURL := s.resource(it).DownloadURL
+		if format := r.URL.Query().Get("format"); format != "" {
+			if !convertible(it.name, format) {
+				writeError(w, http.StatusNotAcceptable, "notSupported", "The file can't be converted to "+format+".")
+				return
+			}
+			downloadURL += "&format=" + url.QueryEscape(format)
+		}
+		http.Redirect(w, r, downloadURL, http.StatusFound)
+	default:
+		writeError(w, http.StatusBadRequest, "invalidRequest", "unsupported request "+r.Method+" "+action)
+	}
+}
+
+// trimDrive trims the drive address of the API path. All the drive
+// addresses, like "me/drive/" or "sites/{id}/drives/{id}/", address the one
+// drive of the server.
+func trimDrive(apiPath string) (string, bool) {
+	elems := strings.SplitN(apiPath, "/", 5)
+	n := 0
+	switch {
+	case len(elems) > 2 && elems[0] == "me" && elems[1] == "drive":
+		n = 2
+	case len(elems) > 2 && elems[0] == "drives":
+		n = 2
+	case len(elems) > 3 && (elems[0] == "users" || elems[0] == "groups" || elems[0] == "sites") && elems[2] == "drive":
+		n = 3
+	case len(elems) > 4 && elems[0] == "sites" && elems[2] == "drives":
+		n = 4
+	default:
+		return "", false
+	}
+	return strings.Join(elems[n:], "/"), true
+}
+
+// parseItemPath parses the escaped item address like "root",
+// "items/{id}/children" or "root:/a/b:/content" to the base item, the path
+// relative to it and the action. The base is nil when there is no item of the
+// ID. The caller must hold the lock.
+func (s *Server) parseItemPath(rest string) (base *item, relPath, action string, err error) {
+	address, addressedPath, hasPath := strings.Cut(rest, ":")
+	if hasPath {
+		escapedPath, escapedAction, _ := strings.Cut(addressedPath, ":")
+		if relPath, err = url.PathUnescape(escapedPath); err != nil {
+			return nil, "", "", err
+		}
+		action = strings.TrimPrefix(escapedAction, "/")
+	}
+	switch elems := strings.SplitN(address, "/", 3); {
+	case elems[0] == "root":
+		base = s.root
+		if len(elems) > 1 {
+			action = strings.Join(elems[1:], "/")
+		}
+	case elems[0] == "items" && len(elems) > 1:
+		id, err := url.PathUnescape(elems[1])
+		if err != nil {
+			return nil, "", "", err
+		}
+		base = s.items[id]
+		if len(elems) > 2 {
+			action = elems[2]
+		}
+	default:
+		return nil, "", "", fmt.Errorf("unknown item address %q", address)
+	}
+	return base, relPath, action, nil
+}
+
+func (s *Server) serveChildren(w http.ResponseWriter, r *http.Request, it *item) {
+	if !it.isDir() {
+		writeError(w, http.StatusBadRequest, "invalidRequest", "The item is not a folder.")
+		return
+	}
+	query := r.URL.Query()
+	pageSize := s.opts.PageSize
+	if top, err := strconv.Atoi(query.Get("$top")); err == nil && top > 0 && top < pageSize {
+		pageSize = top
+	}
+	skip, _ := strconv.Atoi(query.Get("$skiptoken"))
+	children := it.sortedChildren()
+	if filter := query.Get("$filter"); filter != "" {
+		prefix, ok := parseStartsWithFilter(filter)
+		if !ok || s.opts.DisableFilter {
+			writeError(w, http.StatusBadRequest, "invalidRequest", "Unsupported filter.")
+			return
+		}
+		children = slices.DeleteFunc(children, func(child *item) bool {
+			// OneDrive compares the names case-insensitively.
+			return !strings.HasPrefix(strings.ToLower(child.name), strings.ToLower(prefix))
+		})
+	}
+	skip = min(skip, len(children))
+	end := min(skip+pageSize, len(children))
+
+	page := listResponse{Value: []*itemResource{}}
+	for _, child := range children[skip:end] {
+		res := s.resource(child)
+		s.expandThumbnails(r, res, child)
+		page.Value = append(page.Value, res)
+	}
+	if end < len(children) {
+		query.Set("$skiptoken", strconv.Itoa(end))
+		page.NextLink = s.srv.URL + r.URL.EscapedPath() + "?" + query.Encode()
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+// parseStartsWithFilter parses the filter like "startswith(name,'foo')",
+// the only one supported by the fake.
+func parseStartsWithFilter(filter string) (prefix string, ok bool) {
+	quoted, ok := strings.CutPrefix(filter, "startswith(name,'")
+	if !ok {
+		return "", false
+	}
+	quoted, ok = strings.CutSuffix(quoted, "')")
+	if !ok {
+		return "", false
+	}
+	return strings.ReplaceAll(quoted, "''", "'"), true
+}
+
+type listResponse struct {
+	NextLink  string          `json:"@odata.nextLink,omitempty"`
+	DeltaLink string          `json:"@odata.deltaLink,omitempty"`
+	Value     []*itemResource `json:"value"`
+}
+
+func (s *Server) serveCreateFolder(w http.ResponseWriter, r *http.Request, parent *item) {
+	var payload struct {
+		Name             string    `json:"name"`
+		Folder           *struct{} `json:"folder"`
+		ConflictBehavior string    `json:"@microsoft.graph.conflictBehavior"`
+	}
+	if err := decodeJSON(r, &payload); err != nil || payload.Name == "" || payload.Folder == nil {
+		writeError(w, http.StatusBadRequest, "invalidRequest", "Only folders can be created.")
+		return
+	}
+	if !parent.isDir() {
+		writeError(w, http.StatusBadRequest, "invalidRequest", "The parent is not a folder.")
+		return
+	}
+	if _, exists := parent.children[payload.Name]; exists && payload.ConflictBehavior != "replace" {
+		writeError(w, http.StatusConflict, "nameAlreadyExists", "The specified item name already exists.")
+		return
+	}
+	writeJSON(w, http.StatusCreated, s.resource(s.newItem(parent, payload.Name, true)))
+}
+
+func (s *Server) serveUpdate(w http.ResponseWriter, r *http.Request, it *item) {
+	var payload struct {
+		Name            string `json:"name"`
+		ParentReference *struct {
+			ID string `json:"id"`
+		} `json:"parentReference"`
+	}
+	if err := decodeJSON(r, &payload); err != nil {
+		writeError(w, http.StatusBadRequest, "invalidRequest", err.Error())
+		return
+	}
+	if it == s.root {
+		writeError(w, http.StatusForbidden, "notAllowed", "The root can't be moved.")
+		return
+	}
+	parent, name := it.parent, it.name
+	if payload.Name != "" {
+		name = payload.Name
+	}
+	if payload.ParentReference != nil && payload.ParentReference.ID != "" {
+		var ok bool
+		if parent, ok = s.items[payload.ParentReference.ID]; !ok {
+			writeError(w, http.StatusNotFound, "itemNotFound", "The parent could not be found.")
+			return
+		}
+	}
+	if !parent.isDir() {
+		writeError(w, http.StatusBadRequest, "invalidRequest", "The parent is not a folder.")
+		return
+	}
+	for p := parent; p != nil; p = p.parent {
+		if p == it {
+			writeError(w, http.StatusBadRequest, "invalidRequest", "An item can't be moved into itself.")
+			return
+		}
+	}
+	if existing, ok := parent.children[name]; ok && existing != it {
+		if r.URL.Query().Get("@microsoft.graph.conflictBehavior") != "replace" {
+			writeError(w, http.StatusConflict, "nameAlreadyExists", "The specified item name already exists.")
+			return
+		}
+		s.deleteItem(existing)
+	}
+	delete(it.parent.children, it.name)
+	s.touch(it.parent, false)
+	it.parent, it.name = parent, name
+	parent.children[name] = it
+	s.touch(parent, false)
+	s.touch(it, false)
+	writeJSON(w, http.StatusOK, s.resource(it))
+}
+
+func (s *Server) serveUpload(w http.ResponseWriter, r *http.Request, base *item, relPath string) {
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalidRequest", err.Error())
+		return
+	}
+	parent, name, ok := s.prepareWrite(w, base, relPath, r.URL.Query().Get("@microsoft.graph.conflictBehavior"))
+	if !ok {
+		return
+	}
+	status := http.StatusCreated
+	if _, exists := parent.children[name]; exists {
+		status = http.StatusOK
+	}
+	writeJSON(w, status, s.resource(s.writeContent(parent, name, data)))
+}
+
+// prepareWrite creates the missing parent folders of a file to be written and
+// checks the conflict with an existing item. It writes an error response when
+// the file can't be written. The caller must hold the lock.
+func (s *Server) prepareWrite(w http.ResponseWriter, base *item, relPath, conflictBehavior string) (parent *item, name string, ok bool) {
+	dirPath, name := path.Split(strings.Trim(relPath, "/"))
+	if name == "" {
+		writeError(w, http.StatusBadRequest, "invalidRequest", "The file name is missing.")
+		return nil, "", false
+	}
+	parent, err := s.mkdirAll(base, dirPath)
+	if err != nil {
+		writeError(w, http.StatusConflict, "nameAlreadyExists", err.Error())
+		return nil, "", false
+	}
+	if existing, exists := parent.children[name]; exists && (existing.isDir() || conflictBehavior == "fail") {
+		writeError(w, http.StatusConflict, "nameAlreadyExists", "The specified item name already exists.")
+		return nil, "", false
+	}
+	return parent, name, true
+}
+
+// writeContent creates or replaces the content of a file. A replaced file
+// keeps its ID. The caller must hold the lock.
+func (s *Server) writeContent(parent *item, name string, data []byte) *item {
+	it, ok := parent.children[name]
+	if !ok || it.isDir() {
+		it = s.newItem(parent, name, false)
+	} else {
+		it.saveVersion()
+		s.touch(it, true)
+		s.touch(parent, false)
+	}
+	it.content = data
+	return it
+}
+
+// ExpireDownloadURLs invalidates the download URLs issued so far, so the
+// downloads using them fail with 401 Unauthorized, like when the
+// pre-authenticated URLs expire.
+func (s *Server) ExpireDownloadURLs() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.downloadEpoch++
+}
+
+func (s *Server) serveDownload(w http.ResponseWriter, r *http.Request, id string) {
+	s.mu.Lock()
+	if r.URL.Query().Get("e") != strconv.Itoa(s.downloadEpoch) {
+		s.mu.Unlock()
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	it, ok := s.items[id]
+	var (
+		content []byte
+		name    string
+		modTime time.Time
+	)
+	if ok && !it.isDir() {
+		content, name, modTime = it.content, it.name, it.modified
+		if versionID := r.URL.Query().Get("version"); versionID != "" {
+			var v *version
+			if v, ok = it.findVersion(versionID); ok {
+				content, modTime = v.content, v.modified
+			}
+		}
+	}
+	s.mu.Unlock()
+	if !ok || it.isDir() {
+		writeError(w, http.StatusNotFound, "itemNotFound", "The resource could not be found.")
+		return
+	}
+	if format := r.URL.Query().Get("format"); format != "" {
+		content = convert(content, format)
+		name = strings.TrimSuffix(name, path.Ext(name)) + "." + format
+	}
+	http.ServeContent(w, r, name, modTime, bytes.NewReader(content))
+}
+
+// truncatingWriter breaks the connection once the remaining bytes are
+// written.
+type truncatingWriter struct {
+	http.ResponseWriter
+	remaining int64
+}
+
+func (w *truncatingWriter) Write(p []byte) (int, error) {
+	if int64(len(p)) < w.remaining {
+		w.remaining -= int64(len(p))
+		return w.ResponseWriter.Write(p)
+	}
+	_, _ = w.ResponseWriter.Write(p[:w.remaining])
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	panic(http.ErrAbortHandler)
+}
+
+func decodeJSON(r *http.Request, target any) error {
+	return json.NewDecoder(r.Body).Decode(target)
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, map[string]any{
+		"error": map[string]any{
+			"code":    code,
+			"message": message,
+			"innerError": map[string]any{
+				"date":       time.Now().UTC().Format(time.RFC3339),
+				"request-id": "fake-request",
+			},
+		},
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, payload any) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(body)
+}
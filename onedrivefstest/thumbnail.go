@@ -0,0 +1,142 @@
+package onedrivefstest
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// thumbnailExtensions are the extensions of the files which have thumbnails.
+var thumbnailExtensions = []string{".docx", ".gif", ".jpeg", ".jpg", ".pdf", ".png", ".pptx", ".xlsx"}
+
+var customThumbnailSize = regexp.MustCompile(`^c([0-9]+)x([0-9]+)(_crop)?$`)
+
+// thumbnailDimensions returns the dimensions of the thumbnails of the size.
+// The fake doesn't know the aspect ratio of the items, so the thumbnails
+// fill the bounds of their sizes.
+func thumbnailDimensions(size string) (width, height int, ok bool) {
+	switch size {
+	case "small":
+		return 96, 96, true
+	case "medium":
+		return 176, 176, true
+	case "large":
+		return 800, 800, true
+	}
+	m := customThumbnailSize.FindStringSubmatch(size)
+	if m == nil {
+		return 0, 0, false
+	}
+	width, _ = strconv.Atoi(m[1])
+	height, _ = strconv.Atoi(m[2])
+	return width, height, width > 0 && height > 0
+}
+
+// serveThumbnail serves the "thumbnails/0/{size}" action. Only the files of
+// the thumbnailExtensions have thumbnails. The caller must hold the lock.
+func (s *Server) serveThumbnail(w http.ResponseWriter, r *http.Request, it *item, action string) {
+	escapedSize, ok := strings.CutPrefix(action, "thumbnails/0/")
+	if !ok || r.Method != "GET" {
+		writeError(w, http.StatusBadRequest, "invalidRequest", "unsupported request "+r.Method+" "+action)
+		return
+	}
+	size, err := url.PathUnescape(escapedSize)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalidRequest", err.Error())
+		return
+	}
+	width, height, ok := thumbnailDimensions(size)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "invalidRequest", "Invalid thumbnail size "+size+".")
+		return
+	}
+	if !hasThumbnails(it) {
+		writeError(w, http.StatusNotFound, "itemNotFound", "The item has no thumbnail.")
+		return
+	}
+	writeJSON(w, http.StatusOK, s.thumbnailResource(it, size, width, height))
+}
+
+// expandThumbnails adds the thumbnails of the item to its resource when the
+// request expands them with "$expand=thumbnails", optionally selecting the
+// sizes like "thumbnails($select=small,c300x200)". The caller must hold the
+// lock.
+func (s *Server) expandThumbnails(r *http.Request, res *itemResource, it *item) {
+	expand := r.URL.Query().Get("$expand")
+	if expand != "thumbnails" && !strings.HasPrefix(expand, "thumbnails(") {
+		return
+	}
+	sizes := []string{"small", "medium", "large"}
+	if selected, ok := strings.CutPrefix(expand, "thumbnails($select="); ok {
+		sizes = strings.Split(strings.TrimSuffix(selected, ")"), ",")
+	}
+	res.Thumbnails = []thumbnailSet{}
+	if !hasThumbnails(it) {
+		return
+	}
+	set := thumbnailSet{"id": "0"}
+	for _, size := range sizes {
+		if width, height, ok := thumbnailDimensions(size); ok {
+			set[size] = s.thumbnailResource(it, size, width, height)
+		}
+	}
+	res.Thumbnails = append(res.Thumbnails, set)
+}
+
+// thumbnailSet is the JSON representation of the thumbnails of an item, keyed
+// by their sizes.
+type thumbnailSet map[string]any
+
+// hasThumbnails reports whether the item is a file of the
+// thumbnailExtensions.
+func hasThumbnails(it *item) bool {
+	return !it.isDir() && slices.Contains(thumbnailExtensions, strings.ToLower(path.Ext(it.name)))
+}
+
+// thumbnailResource returns the JSON representation of the thumbnail of the
+// size. The caller must hold the lock.
+func (s *Server) thumbnailResource(it *item, size string, width, height int) map[string]any {
+	return map[string]any{
+		"width":  width,
+		"height": height,
+		"size":   len(thumbnailContent(it.name, size)),
+		"url":    fmt.Sprintf("%s/thumbnail/%s/%s?e=%d", s.srv.URL, it.id, url.PathEscape(size), s.downloadEpoch),
+	}
+}
+
+// thumbnailContent returns the fake image of the thumbnail of the size of the
+// named item.
+func thumbnailContent(name, size string) string {
+	return fmt.Sprintf("\xff\xd8\xff fake %s thumbnail of %s", size, name)
+}
+
+// serveThumbnailContent serves a fake image of the thumbnail, naming the
+// item and the size.
+func (s *Server) serveThumbnailContent(w http.ResponseWriter, r *http.Request, rest string) {
+	id, size, _ := strings.Cut(rest, "/")
+	s.mu.Lock()
+	it, ok := s.items[id]
+	valid := r.URL.Query().Get("e") == strconv.Itoa(s.downloadEpoch)
+	var name string
+	if ok {
+		name = it.name
+	}
+	s.mu.Unlock()
+	if !valid {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if !ok {
+		writeError(w, http.StatusNotFound, "itemNotFound", "The resource could not be found.")
+		return
+	}
+	size, _ = url.PathUnescape(size)
+	w.Header().Set("Content-Type", "image/jpeg")
+	_, _ = io.WriteString(w, thumbnailContent(name, size))
+}
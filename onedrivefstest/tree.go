@@ -0,0 +1,307 @@
+package onedrivefstest
+
+import (
+	"cmp"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io/fs"
+	"maps"
+	"mime"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"go.dataddo.com/onedrivefs/quickxorhash"
+)
+
+// item is a file or a folder of the in-memory drive.
+type item struct {
+	id       string
+	name     string
+	parent   *item
+	children map[string]*item // nil for files
+	content  []byte
+	created  time.Time
+	modified time.Time
+	// eTag changes with any change of the item, cTag with its content.
+	eTag int
+	cTag int
+	// changed is the sequence number of the last change, see Server.seq.
+	changed int
+	// versions are the previous versions of a file, the newest first.
+	versions []*version
+	links    []*sharingLink
+}
+
+func (it *item) isDir() bool { return it.children != nil }
+
+// path returns the path of the item relative to the drive root.
+func (it *item) path() string {
+	if it.parent == nil {
+		return ""
+	}
+	return path.Join(it.parent.path(), it.name)
+}
+
+func (it *item) size() int64 {
+	if !it.isDir() {
+		return int64(len(it.content))
+	}
+	var size int64
+	for _, child := range it.children {
+		size += child.size()
+	}
+	return size
+}
+
+// sortedChildren returns the children ordered by name.
+func (it *item) sortedChildren() []*item {
+	// OneDrive orders the names case-insensitively.
+	return slices.SortedFunc(maps.Values(it.children), func(a, b *item) int {
+		return cmp.Or(
+			strings.Compare(strings.ToLower(a.name), strings.ToLower(b.name)),
+			strings.Compare(a.name, b.name),
+		)
+	})
+}
+
+// newItem creates an item in the parent folder, replacing any item of the
+// same name. The caller must hold the lock.
+func (s *Server) newItem(parent *item, name string, dir bool) *item {
+	s.lastID++
+	now := s.now()
+	it := &item{
+		id:       fmt.Sprintf("FAKE%012X", s.lastID),
+		name:     name,
+		parent:   parent,
+		created:  now,
+		modified: now,
+		eTag:     1,
+		cTag:     1,
+		changed:  s.nextSeq(),
+	}
+	if dir {
+		it.children = map[string]*item{}
+	}
+	if parent != nil {
+		if old, ok := parent.children[name]; ok {
+			s.deleteItem(old)
+		}
+		parent.children[name] = it
+		s.touch(parent, false)
+	}
+	s.items[it.id] = it
+	return it
+}
+
+// deleteItem removes the item with all its descendants. The caller must hold
+// the lock.
+func (s *Server) deleteItem(it *item) {
+	// The tombstone of a folder precedes the ones of its descendants, like
+	// the parents precede their children in delta responses.
+	s.tombstones = append(s.tombstones, &tombstone{
+		id:       it.id,
+		name:     it.name,
+		parentID: it.parent.id,
+		path:     it.path(),
+		dir:      it.isDir(),
+		seq:      s.nextSeq(),
+	})
+	for _, child := range it.children {
+		s.deleteItem(child)
+	}
+	delete(s.items, it.id)
+	if it.parent != nil && it.parent.children[it.name] == it {
+		delete(it.parent.children, it.name)
+		s.touch(it.parent, false)
+	}
+}
+
+// touch marks the item as modified. The caller must hold the lock.
+func (s *Server) touch(it *item, content bool) {
+	it.modified = s.now()
+	it.changed = s.nextSeq()
+	it.eTag++
+	if content {
+		it.cTag++
+	}
+}
+
+// lookup finds the item at the slash separated path relative to the base
+// item. The caller must hold the lock.
+func (s *Server) lookup(base *item, itemPath string) (*item, bool) {
+	it := base
+	for _, elem := range strings.Split(itemPath, "/") {
+		if elem == "" || elem == "." {
+			continue
+		}
+		if !it.isDir() {
+			return nil, false
+		}
+		child, ok := it.children[elem]
+		if !ok {
+			return nil, false
+		}
+		it = child
+	}
+	return it, true
+}
+
+// mkdirAll finds the folder at the path, creating the missing folders. The
+// caller must hold the lock.
+func (s *Server) mkdirAll(base *item, dirPath string) (*item, error) {
+	dir := base
+	for _, elem := range strings.Split(dirPath, "/") {
+		if elem == "" || elem == "." {
+			continue
+		}
+		child, ok := dir.children[elem]
+		switch {
+		case !ok:
+			child = s.newItem(dir, elem, true)
+		case !child.isDir():
+			return nil, fmt.Errorf("%s: %w", child.path(), fs.ErrExist)
+		}
+		dir = child
+	}
+	return dir, nil
+}
+
+func (s *Server) now() time.Time {
+	return s.opts.Now().UTC().Truncate(time.Second)
+}
+
+// nextSeq returns the sequence number of a new change. The caller must hold
+// the lock.
+func (s *Server) nextSeq() int {
+	s.seq++
+	return s.seq
+}
+
+// itemResource is the JSON representation of a drive item.
+type itemResource struct {
+	ID                   string         `json:"id"`
+	Name                 string         `json:"name"`
+	ETag                 string         `json:"eTag"`
+	CTag                 string         `json:"cTag"`
+	Size                 int64          `json:"size"`
+	CreatedDateTime      time.Time      `json:"createdDateTime"`
+	LastModifiedDateTime time.Time      `json:"lastModifiedDateTime"`
+	WebURL               string         `json:"webUrl"`
+	DownloadURL          string         `json:"@microsoft.graph.downloadUrl,omitempty"`
+	Root                 *struct{}      `json:"root,omitempty"`
+	Folder               *folderFacet   `json:"folder,omitempty"`
+	File                 *fileFacet     `json:"file,omitempty"`
+	ParentReference      *itemReference `json:"parentReference,omitempty"`
+	Deleted              *deletedFacet  `json:"deleted,omitempty"`
+	CreatedBy            *identitySet   `json:"createdBy,omitempty"`
+	LastModifiedBy       *identitySet   `json:"lastModifiedBy,omitempty"`
+	Thumbnails           []thumbnailSet `json:"thumbnails,omitempty"`
+}
+
+type folderFacet struct {
+	ChildCount int `json:"childCount"`
+}
+
+type fileFacet struct {
+	MimeType string `json:"mimeType"`
+	Hashes   hashes `json:"hashes"`
+}
+
+type deletedFacet struct {
+	State string `json:"state"`
+}
+
+type hashes struct {
+	QuickXorHash string `json:"quickXorHash,omitempty"`
+	SHA1Hash     string `json:"sha1Hash,omitempty"`
+	SHA256Hash   string `json:"sha256Hash,omitempty"`
+}
+
+type itemReference struct {
+	DriveID string `json:"driveId"`
+	ID      string `json:"id,omitempty"`
+	Path    string `json:"path,omitempty"`
+}
+
+type identitySet struct {
+	User identity `json:"user"`
+}
+
+type identity struct {
+	ID          string `json:"id"`
+	DisplayName string `json:"displayName"`
+}
+
+// resource returns the JSON representation of the item. The caller must hold
+// the lock.
+func (s *Server) resource(it *item) *itemResource {
+	res := &itemResource{
+		ID:                   it.id,
+		Name:                 it.name,
+		ETag:                 fmt.Sprintf(`"{%s},%d"`, it.id, it.eTag),
+		CTag:                 fmt.Sprintf(`"c:{%s},%d"`, it.id, it.cTag),
+		Size:                 it.size(),
+		CreatedDateTime:      it.created,
+		LastModifiedDateTime: it.modified,
+		WebURL:               s.srv.URL + "/web/" + it.path(),
+		CreatedBy:            &identitySet{User: fakeUser},
+		LastModifiedBy:       &identitySet{User: fakeUser},
+	}
+	if it.isDir() {
+		res.Folder = &folderFacet{ChildCount: len(it.children)}
+	} else {
+		res.File = &fileFacet{
+			MimeType: mimeType(it.name),
+			Hashes: hashes{
+				QuickXorHash: quickXorHash(it.content),
+				SHA1Hash:     fmt.Sprintf("%X", sha1.Sum(it.content)),
+				SHA256Hash:   fmt.Sprintf("%X", sha256.Sum256(it.content)),
+			},
+		}
+		res.DownloadURL = fmt.Sprintf("%s/download/%s?v=%d&e=%d", s.srv.URL, it.id, it.cTag, s.downloadEpoch)
+	}
+	if it.parent == nil {
+		res.Root = &struct{}{}
+		res.Name = "root"
+	} else {
+		parentPath := "/drive/root:"
+		if p := it.parent.path(); p != "" {
+			parentPath += "/" + escapePath(p)
+		}
+		res.ParentReference = &itemReference{
+			DriveID: DriveID,
+			ID:      it.parent.id,
+			Path:    parentPath,
+		}
+	}
+	return res
+}
+
+var fakeUser = identity{ID: "fake-user", DisplayName: "Fake User"}
+
+func mimeType(name string) string {
+	if typ := mime.TypeByExtension(path.Ext(name)); typ != "" {
+		return typ
+	}
+	return "application/octet-stream"
+}
+
+// escapePath percent-encodes the elements of a slash separated path.
+func escapePath(itemPath string) string {
+	elems := strings.Split(itemPath, "/")
+	for i, elem := range elems {
+		elems[i] = url.PathEscape(elem)
+	}
+	return strings.Join(elems, "/")
+}
+
+func quickXorHash(content []byte) string {
+	h := quickxorhash.New()
+	_, _ = h.Write(content)
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
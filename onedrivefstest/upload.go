@@ -0,0 +1,108 @@
+package onedrivefstest
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// uploadSession is an upload of a file in fragments.
+type uploadSession struct {
+	parent           *item
+	name             string
+	conflictBehavior string
+	data             []byte
+	expires          time.Time
+}
+
+type uploadSessionResource struct {
+	UploadURL          string    `json:"uploadUrl,omitempty"`
+	ExpirationDateTime time.Time `json:"expirationDateTime"`
+	NextExpectedRanges []string  `json:"nextExpectedRanges"`
+}
+
+// resource returns the JSON representation of the session.
+func (us *uploadSession) resource() *uploadSessionResource {
+	return &uploadSessionResource{
+		ExpirationDateTime: us.expires,
+		NextExpectedRanges: []string{strconv.Itoa(len(us.data)) + "-"},
+	}
+}
+
+func (s *Server) serveCreateUploadSession(w http.ResponseWriter, r *http.Request, base *item, relPath string) {
+	var payload struct {
+		Item struct {
+			ConflictBehavior string `json:"@microsoft.graph.conflictBehavior"`
+		} `json:"item"`
+	}
+	if r.ContentLength != 0 {
+		if err := decodeJSON(r, &payload); err != nil {
+			writeError(w, http.StatusBadRequest, "invalidRequest", err.Error())
+			return
+		}
+	}
+	parent, name, ok := s.prepareWrite(w, base, relPath, payload.Item.ConflictBehavior)
+	if !ok {
+		return
+	}
+	s.lastID++
+	id := fmt.Sprintf("SESSION%d", s.lastID)
+	session := &uploadSession{
+		parent:           parent,
+		name:             name,
+		conflictBehavior: payload.Item.ConflictBehavior,
+		expires:          s.now().Add(24 * time.Hour),
+	}
+	s.sessions[id] = session
+	res := session.resource()
+	res.UploadURL = s.srv.URL + "/upload/" + id
+	writeJSON(w, http.StatusOK, res)
+}
+
+// serveUploadSession serves the pre-authenticated upload URL of a session.
+func (s *Server) serveUploadSession(w http.ResponseWriter, r *http.Request, id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, ok := s.sessions[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, "itemNotFound", "The upload session could not be found.")
+		return
+	}
+	switch r.Method {
+	case "GET":
+		writeJSON(w, http.StatusOK, session.resource())
+	case "DELETE":
+		delete(s.sessions, id)
+		w.WriteHeader(http.StatusNoContent)
+	case "PUT":
+		var start, end, total int64
+		if _, err := fmt.Sscanf(r.Header.Get("Content-Range"), "bytes %d-%d/%d", &start, &end, &total); err != nil {
+			writeError(w, http.StatusBadRequest, "invalidRequest", "invalid Content-Range")
+			return
+		}
+		if start != int64(len(session.data)) || end < start || end >= total {
+			writeError(w, http.StatusRequestedRangeNotSatisfiable, "invalidRange", "The fragment doesn't match the expected range.")
+			return
+		}
+		fragment, err := io.ReadAll(r.Body)
+		if err != nil || int64(len(fragment)) != end-start+1 {
+			writeError(w, http.StatusBadRequest, "invalidRequest", "The fragment doesn't match its Content-Range.")
+			return
+		}
+		session.data = append(session.data, fragment...)
+		if int64(len(session.data)) < total {
+			writeJSON(w, http.StatusAccepted, session.resource())
+			return
+		}
+		delete(s.sessions, id)
+		if existing, exists := session.parent.children[session.name]; exists && (existing.isDir() || session.conflictBehavior == "fail") {
+			writeError(w, http.StatusConflict, "nameAlreadyExists", "The specified item name already exists.")
+			return
+		}
+		writeJSON(w, http.StatusCreated, s.resource(s.writeContent(session.parent, session.name, session.data)))
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "invalidRequest", "unsupported method")
+	}
+}
@@ -0,0 +1,114 @@
+package onedrivefstest
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// version is a previous version of a file, replaced by a write of its
+// content.
+type version struct {
+	id       string
+	content  []byte
+	modified time.Time
+}
+
+// versionResource is the JSON representation of a file version.
+type versionResource struct {
+	ID                   string       `json:"id"`
+	Size                 int64        `json:"size"`
+	LastModifiedDateTime time.Time    `json:"lastModifiedDateTime"`
+	LastModifiedBy       *identitySet `json:"lastModifiedBy,omitempty"`
+}
+
+// currentVersionID returns the ID of the current version of the file, which
+// is numbered by the content tag.
+func (it *item) currentVersionID() string {
+	return fmt.Sprintf("%d.0", it.cTag)
+}
+
+// saveVersion keeps the current content of the file as a previous version,
+// before it's replaced. The caller must hold the lock.
+func (it *item) saveVersion() {
+	it.versions = append([]*version{{
+		id:       it.currentVersionID(),
+		content:  it.content,
+		modified: it.modified,
+	}}, it.versions...)
+}
+
+// findVersion returns the version of the ID, the current one included. The
+// caller must hold the lock.
+func (it *item) findVersion(id string) (*version, bool) {
+	if id == it.currentVersionID() {
+		return &version{id: id, content: it.content, modified: it.modified}, true
+	}
+	for _, v := range it.versions {
+		if v.id == id {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// serveVersions serves the version actions of the file: the "versions"
+// listing, and "versions/{id}" with the optional "content" and
+// "restoreVersion" actions. The caller must hold the lock.
+func (s *Server) serveVersions(w http.ResponseWriter, r *http.Request, it *item, action string) {
+	if it.isDir() {
+		writeError(w, http.StatusBadRequest, "invalidRequest", "Folders have no versions.")
+		return
+	}
+	rest := strings.TrimPrefix(action, "versions")
+	if rest == "" {
+		if r.Method != "GET" {
+			writeError(w, http.StatusBadRequest, "invalidRequest", "unsupported request "+r.Method+" "+action)
+			return
+		}
+		value := []*versionResource{s.versionResource(&version{id: it.currentVersionID(), content: it.content, modified: it.modified})}
+		for _, v := range it.versions {
+			value = append(value, s.versionResource(v))
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"value": value})
+		return
+	}
+	escapedID, versionAction, _ := strings.Cut(strings.TrimPrefix(rest, "/"), "/")
+	id, err := url.PathUnescape(escapedID)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalidRequest", err.Error())
+		return
+	}
+	v, ok := it.findVersion(id)
+	if !ok {
+		writeError(w, http.StatusNotFound, "itemNotFound", "The version could not be found.")
+		return
+	}
+	switch {
+	case r.Method == "GET" && versionAction == "":
+		writeJSON(w, http.StatusOK, s.versionResource(v))
+	case r.Method == "GET" && versionAction == "content":
+		downloadURL := fmt.Sprintf("%s/download/%s?version=%s&e=%d", s.srv.URL, it.id, url.QueryEscape(v.id), s.downloadEpoch)
+		http.Redirect(w, r, downloadURL, http.StatusFound)
+	case r.Method == "POST" && versionAction == "restoreVersion":
+		// The restored content becomes a new version.
+		it.saveVersion()
+		s.touch(it, true)
+		s.touch(it.parent, false)
+		it.content = v.content
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusBadRequest, "invalidRequest", "unsupported request "+r.Method+" "+action)
+	}
+}
+
+func (s *Server) versionResource(v *version) *versionResource {
+	return &versionResource{
+		ID:                   v.id,
+		Size:                 int64(len(v.content)),
+		LastModifiedDateTime: v.modified,
+		LastModifiedBy:       &identitySet{User: fakeUser},
+	}
+}
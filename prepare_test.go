@@ -48,7 +48,7 @@ func mkdir(t *testing.T, authClient *http.Client, parentID string, folderName st
 		ConflictBehavior: "replace",
 	})
 	noErr(t, err)
-	apiURL := "https://graph.microsoft.com/v1.0/me/drive/items/" + url.PathEscape(parentID) + "/children"
+	apiURL := testEndpoint() + "me/drive/items/" + url.PathEscape(parentID) + "/children"
 	req, err := http.NewRequest("POST", apiURL, bytes.NewReader(reqBody))
 	noErr(t, err)
 	req.Header.Set("Content-Type", "application/json")
@@ -74,7 +74,7 @@ func mkdir(t *testing.T, authClient *http.Client, parentID string, folderName st
 func uploadFile(t *testing.T, authClient *http.Client, dirID, fileName, fileType string, fileData io.Reader) {
 	req, err := http.NewRequest(
 		"PUT",
-		"https://graph.microsoft.com/v1.0/me/drive/items/"+url.PathEscape(dirID)+":/"+url.PathEscape(fileName)+":/content?@microsoft.graph.conflictBehavior=replace",
+		testEndpoint()+"me/drive/items/"+url.PathEscape(dirID)+":/"+url.PathEscape(fileName)+":/content?@microsoft.graph.conflictBehavior=replace",
 		fileData,
 	)
 	noErr(t, err)
@@ -88,3 +88,12 @@ func uploadFile(t *testing.T, authClient *http.Client, dirID, fileName, fileType
 		t.Fatal("ERROR:", body)
 	}
 }
+
+// testEndpoint returns the Graph API endpoint of the test drive, which can be
+// set by ONEDRIVE_ENDPOINT, e.g. to test a national cloud.
+func testEndpoint() string {
+	if endpoint := os.Getenv("ONEDRIVE_ENDPOINT"); endpoint != "" {
+		return strings.TrimSuffix(endpoint, "/") + "/"
+	}
+	return DefaultEndpoint
+}
@@ -0,0 +1,82 @@
+// Package quickxorhash implements the QuickXorHash of OneDrive, the hash of
+// the file content reported by OneDrive for Business and SharePoint.
+//
+// The hash XORs the bytes of the content into a 160-bit register, shifting
+// every byte by 11 bits more than the previous one and wrapping around, and
+// XORs the content length into the last 64 bits. OneDrive reports the hash
+// base64 encoded.
+//
+// Ref https://learn.microsoft.com/en-us/onedrive/developer/code-snippets/quickxorhash
+package quickxorhash
+
+import (
+	"encoding/binary"
+	"hash"
+)
+
+const (
+	// Size is the size of the hash in bytes.
+	Size = 20
+	// BlockSize is the preferred size of the writes to the hash.
+	BlockSize = 64
+
+	widthInBits = Size * 8
+	shift       = 11
+)
+
+type digest struct {
+	// cells are the XORs of the bytes at the same position modulo the width,
+	// which are shifted by the same number of bits.
+	cells [widthInBits]byte
+	// pos is the position of the next byte modulo the width.
+	pos    int
+	length uint64
+}
+
+// New returns a new hash.Hash computing the QuickXorHash.
+func New() hash.Hash {
+	return &digest{}
+}
+
+func (d *digest) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		m := min(len(p), widthInBits-d.pos)
+		cells := d.cells[d.pos : d.pos+m]
+		for i, b := range p[:m] {
+			cells[i] ^= b
+		}
+		d.pos = (d.pos + m) % widthInBits
+		p = p[m:]
+	}
+	d.length += uint64(n)
+	return n, nil
+}
+
+// Sum appends the hash to b, the bits of the register in the little-endian
+// order.
+func (d *digest) Sum(b []byte) []byte {
+	var sum [Size]byte
+	for i, cell := range d.cells {
+		if cell == 0 {
+			continue
+		}
+		bit := i * shift % widthInBits
+		sum[bit/8] ^= cell << (bit % 8)
+		if bit%8 != 0 {
+			sum[(bit/8+1)%Size] ^= cell >> (8 - bit%8)
+		}
+	}
+	var length [8]byte
+	binary.LittleEndian.PutUint64(length[:], d.length)
+	for i, l := range length {
+		sum[Size-len(length)+i] ^= l
+	}
+	return append(b, sum[:]...)
+}
+
+func (d *digest) Reset() { *d = digest{} }
+
+func (d *digest) Size() int { return Size }
+
+func (d *digest) BlockSize() int { return BlockSize }
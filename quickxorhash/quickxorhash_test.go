@@ -0,0 +1,77 @@
+package quickxorhash
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"math/rand/v2"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		content string
+		want    string
+	}{
+		{"", "AAAAAAAAAAAAAAAAAAAAAAAAAAA="},
+		{"J", "SgAAAAAAAAAAAAAAAQAAAAAAAAA="},
+	}
+	for _, tt := range tests {
+		h := New()
+		_, _ = h.Write([]byte(tt.content))
+		if got := base64.StdEncoding.EncodeToString(h.Sum(nil)); got != tt.want {
+			t.Errorf("%q: want %s, got %s", tt.content, tt.want, got)
+		}
+	}
+}
+
+func TestNew_naive(t *testing.T) {
+	rnd := rand.New(rand.NewPCG(1, 2))
+	for _, size := range []int{1, 2, 19, 20, 159, 160, 161, 1000, 100_000} {
+		content := make([]byte, size)
+		for i := range content {
+			content[i] = byte(rnd.Uint32())
+		}
+		want := naive(content)
+
+		h := New()
+		for p := content; len(p) > 0; {
+			n := min(len(p), 1+rnd.IntN(300))
+			_, _ = h.Write(p[:n])
+			p = p[n:]
+		}
+		if got := h.Sum([]byte("prefix")); !bytes.Equal(append([]byte("prefix"), want...), got) {
+			t.Errorf("size %d: want %x, got %x", size, want, got[len("prefix"):])
+		}
+		h.Reset()
+		_, _ = h.Write(content)
+		if got := h.Sum(nil); !bytes.Equal(want, got) {
+			t.Errorf("size %d after reset: want %x, got %x", size, want, got)
+		}
+	}
+}
+
+// naive computes the hash bit by bit, rotating every byte into a 160-bit
+// register.
+func naive(content []byte) []byte {
+	var bits [widthInBits]bool
+	for i, b := range content {
+		for j := range 8 {
+			if b&(1<<j) != 0 {
+				k := (i*shift + j) % widthInBits
+				bits[k] = !bits[k]
+			}
+		}
+	}
+	sum := make([]byte, Size)
+	for k, bit := range bits {
+		if bit {
+			sum[k/8] |= 1 << (k % 8)
+		}
+	}
+	length := binary.LittleEndian.AppendUint64(nil, uint64(len(content)))
+	for i, l := range length {
+		sum[Size-8+i] ^= l
+	}
+	return sum
+}
@@ -0,0 +1,89 @@
+package onedrivefs
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// pathResolver resolves the paths of the items listed across the whole drive,
+// like by delta queries or search, relative to the root of the FS.
+type pathResolver struct {
+	fs  *FS
+	ctx context.Context
+	// paths maps the IDs of the folders to their paths in the drive.
+	paths map[string]string
+	// rootPath is the path of the root of the FS in the drive.
+	rootPath string
+}
+
+func newPathResolver(ctx context.Context, f *FS) *pathResolver {
+	return &pathResolver{
+		fs:    f,
+		ctx:   ctx,
+		paths: map[string]string{},
+	}
+}
+
+// resolveRoot resolves the path of the root of an FS returned by Sub, which
+// may have been moved since.
+func (r *pathResolver) resolveRoot() error {
+	if r.fs.rootID == "" {
+		return nil
+	}
+	root, err := r.fs.getDriveItemByID(r.ctx, r.fs.rootID)
+	if err != nil {
+		return err
+	}
+	r.rootPath, err = r.drivePath(root)
+	return err
+}
+
+// drivePath returns the path of the item in the drive. The responses don't
+// always include the paths of the parents, so the unknown ones are looked up
+// by ID.
+func (r *pathResolver) drivePath(item *driveItem) (string, error) {
+	if item.Root != nil {
+		r.paths[item.ID] = ""
+		return "", nil
+	}
+	parent := item.ParentReference
+	if parent == nil {
+		return "", fmt.Errorf("the API didn't provide parent of item %s", item.ID)
+	}
+	parentPath, ok := r.paths[parent.ID]
+	if !ok {
+		if _, escapedPath, found := strings.Cut(parent.Path, "root:"); found {
+			escapedPath = strings.TrimPrefix(escapedPath, "/")
+			var err error
+			if parentPath, err = url.PathUnescape(escapedPath); err != nil {
+				parentPath = escapedPath
+			}
+		} else {
+			parentItem, err := r.fs.getDriveItemByID(r.ctx, parent.ID)
+			if err != nil {
+				return "", err
+			}
+			if parentPath, err = r.drivePath(parentItem); err != nil {
+				return "", err
+			}
+		}
+	}
+	itemPath := path.Join(parentPath, item.Name)
+	if item.Folder != nil {
+		r.paths[item.ID] = itemPath
+	}
+	return itemPath, nil
+}
+
+// relPath returns the path relative to the root of the FS of the item at the
+// path in the drive. It reports false for the paths outside of the FS and for
+// its root.
+func (r *pathResolver) relPath(drivePath string) (string, bool) {
+	if r.rootPath == "" {
+		return drivePath, drivePath != ""
+	}
+	return strings.CutPrefix(drivePath, r.rootPath+"/")
+}
@@ -0,0 +1,135 @@
+package onedrivefs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy configures retrying of requests failed because of throttling
+// (429), unavailability of the service (503, 504) or a reset connection. The
+// time spent retrying is bounded by the FS context.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts of a request, including
+	// the first one. Values lower than 2 disable retrying.
+	MaxAttempts int
+	// BaseDelay is the delay before the first retry. It doubles with every
+	// following attempt.
+	BaseDelay time.Duration
+	// MaxDelay caps the delay between attempts. It doesn't apply when the
+	// server asks for a longer delay with the Retry-After header.
+	MaxDelay time.Duration
+}
+
+// DefaultRetryPolicy is used when DriveOpts.Retry is nil.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 5,
+	BaseDelay:   time.Second,
+	MaxDelay:    time.Minute,
+}
+
+// do sends the request with the client, retrying it when it fails with
+// a retryable error. The last response is returned as is, so the caller can
+// handle the error.
+func (p *RetryPolicy) do(ctx context.Context, client *http.Client, req *http.Request) (*http.Response, error) {
+	req = req.WithContext(ctx)
+	for attempt := 1; ; attempt++ {
+		if attempt > 1 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+		resp, err := client.Do(req)
+		if attempt >= p.MaxAttempts || !isRetryable(ctx, req, resp, err) {
+			return resp, err
+		}
+		delay := p.delay(attempt, resp)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			// There is no time left to wait for another attempt.
+			return resp, err
+		}
+		if resp != nil {
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+		}
+		if err := sleep(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// sleep waits for the delay, returning early with the error of the context
+// when it's done.
+func sleep(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// delay returns how long to wait before the attempt following the given one.
+func (p *RetryPolicy) delay(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if delay, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+			return delay
+		}
+	}
+	delay := p.BaseDelay << (attempt - 1)
+	if delay <= 0 || (p.MaxDelay > 0 && delay > p.MaxDelay) {
+		delay = p.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	// Equal jitter spreads the retries of concurrent requests apart.
+	return delay/2 + rand.N(delay/2+1)
+}
+
+func isRetryable(ctx context.Context, req *http.Request, resp *http.Response, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		// The body was consumed and can't be sent again.
+		return false
+	}
+	if err != nil {
+		var netErr net.Error
+		return errors.Is(err, syscall.ECONNRESET) ||
+			errors.Is(err, io.ErrUnexpectedEOF) ||
+			errors.Is(err, io.EOF) ||
+			(errors.As(err, &netErr) && netErr.Timeout())
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// parseRetryAfter parses the value of the Retry-After header, which is either
+// a number of seconds or an HTTP date.
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return max(time.Duration(seconds)*time.Second, 0), true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0), true
+	}
+	return 0, false
+}
@@ -0,0 +1,95 @@
+package onedrivefs
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicy_do(t *testing.T) {
+	tests := []struct {
+		name         string
+		policy       RetryPolicy
+		responses    []int
+		retryAfter   string
+		timeout      time.Duration
+		wantStatus   int
+		wantAttempts int32
+	}{
+		{
+			name:         "success after throttling",
+			policy:       RetryPolicy{MaxAttempts: 5, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond},
+			responses:    []int{http.StatusTooManyRequests, http.StatusServiceUnavailable, http.StatusOK},
+			wantStatus:   http.StatusOK,
+			wantAttempts: 3,
+		},
+		{
+			name:         "attempts exhausted",
+			policy:       RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond},
+			responses:    []int{http.StatusGatewayTimeout, http.StatusGatewayTimeout, http.StatusOK},
+			wantStatus:   http.StatusGatewayTimeout,
+			wantAttempts: 2,
+		},
+		{
+			name:         "not retryable",
+			policy:       RetryPolicy{MaxAttempts: 5, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond},
+			responses:    []int{http.StatusNotFound, http.StatusOK},
+			wantStatus:   http.StatusNotFound,
+			wantAttempts: 1,
+		},
+		{
+			name:         "retry-after beyond the deadline",
+			policy:       RetryPolicy{MaxAttempts: 5, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond},
+			responses:    []int{http.StatusTooManyRequests, http.StatusOK},
+			retryAfter:   "120",
+			timeout:      time.Second,
+			wantStatus:   http.StatusTooManyRequests,
+			wantAttempts: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := attempts.Add(1)
+				if tt.retryAfter != "" {
+					w.Header().Set("Retry-After", tt.retryAfter)
+				}
+				w.WriteHeader(tt.responses[n-1])
+			}))
+			t.Cleanup(srv.Close)
+
+			ctx := t.Context()
+			if tt.timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.timeout)
+				t.Cleanup(cancel)
+			}
+			req, err := http.NewRequest("GET", srv.URL, nil)
+			noErr(t, err)
+			resp, err := tt.policy.do(ctx, srv.Client(), req)
+			noErr(t, err)
+			_ = resp.Body.Close()
+			assertEqual(t, tt.wantStatus, resp.StatusCode, "status")
+			assertEqual(t, tt.wantAttempts, attempts.Load(), "attempts")
+		})
+	}
+}
+
+func Test_parseRetryAfter(t *testing.T) {
+	delay, ok := parseRetryAfter("7")
+	assertEqual(t, true, ok, "seconds")
+	assertEqual(t, 7*time.Second, delay, "seconds")
+
+	delay, ok = parseRetryAfter(time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+	assertEqual(t, true, ok, "date")
+	if delay < 59*time.Minute || delay > time.Hour {
+		t.Errorf("want delay about an hour, got %v", delay)
+	}
+
+	_, ok = parseRetryAfter("soon")
+	assertEqual(t, false, ok, "invalid")
+}
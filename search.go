@@ -0,0 +1,87 @@
+package onedrivefs
+
+import (
+	"context"
+	"io/fs"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// SearchOptions configures FS.Search.
+type SearchOptions struct {
+	// Limit is the maximum number of the results. All the results are
+	// returned when zero.
+	Limit int
+}
+
+// SearchResult is an item found by FS.Search.
+type SearchResult struct {
+	// Path is the slash separated path of the item, the same as accepted by
+	// Open.
+	Path string
+	// Info describes the item the same as the FileInfo returned by Stat.
+	Info fs.FileInfo
+}
+
+// Search returns the items whose names, metadata or content match the query,
+// in the order of relevance determined by OneDrive. OneDrive searches the
+// whole drive, so the results outside an FS returned by Sub are left out.
+//
+// The search index of OneDrive is updated asynchronously, the recent changes
+// may not be reflected in the results.
+//
+// OneDrive API docs: https://learn.microsoft.com/en-us/graph/api/driveitem-search
+func (f *FS) Search(ctx context.Context, query string, opts SearchOptions) ([]SearchResult, error) {
+	if ctx == nil {
+		ctx = f.ctx
+	}
+	paths := newPathResolver(ctx, f)
+	if err := paths.resolveRoot(); err != nil {
+		return nil, err
+	}
+	req, err := f.newRequest("GET", f.searchURL(query), nil)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Limit > 0 {
+		req.URL.RawQuery = url.Values{"$top": {strconv.Itoa(opts.Limit)}}.Encode()
+	}
+	var page *driveItemsResponse
+	if err := f.doRequest(ctx, req, &page); err != nil {
+		return nil, err
+	}
+	var results []SearchResult
+	for {
+		for _, item := range page.DriveItems {
+			drivePath, err := paths.drivePath(item)
+			if err != nil {
+				return nil, err
+			}
+			relPath, ok := paths.relPath(drivePath)
+			if !ok {
+				continue
+			}
+			info := newFileInfo(item)
+			results = append(results, SearchResult{Path: relPath, Info: &info})
+			if len(results) == opts.Limit {
+				return results, nil
+			}
+		}
+		if page.NextLink == "" {
+			return results, nil
+		}
+		if page, err = f.listDriveItemsNextPage(ctx, page.NextLink); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// searchURL returns the API URL of the search. Only OneDrive personal
+// supports searching in folders, so the whole drive is searched.
+func (f *FS) searchURL(query string) string {
+	q := url.PathEscape(strings.ReplaceAll(query, "'", "''"))
+	// Colons separate the paths in the API URLs.
+	q = strings.ReplaceAll(q, ":", "%3A")
+	return f.opts.Drive.apiPath() + "/root/search(q='" + q + "')"
+}
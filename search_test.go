@@ -0,0 +1,86 @@
+package onedrivefs
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"go.dataddo.com/onedrivefs/onedrivefstest"
+)
+
+func TestFS_Search(t *testing.T) {
+	fsys, srv := initFakeFileSystem(t, onedrivefstest.Options{PageSize: 1})
+	noErr(t, srv.WriteFile("other/foo.txt", nil))
+	noErr(t, srv.WriteFile("subdir1/it's here.txt", nil))
+	ctx := context.Background()
+	sub, err := fsys.Sub("subdir1")
+	noErr(t, err)
+
+	tests := []struct {
+		name  string
+		fsys  *FS
+		query string
+		opts  SearchOptions
+		want  []string
+	}{
+		{
+			name:  "name",
+			fsys:  fsys,
+			query: "foo",
+			want:  []string{"other/foo.txt", "subdir1/subdir2/foo-json", "subdir1/subdir2/foo.csv", "subdir1/subdir2/foo.json"},
+		},
+		{
+			name:  "content",
+			fsys:  fsys,
+			query: "is JSON",
+			want:  []string{"subdir1/subdir2/foo.json"},
+		},
+		{
+			name:  "sub",
+			fsys:  sub.(*FS),
+			query: "foo",
+			want:  []string{"subdir2/foo-json", "subdir2/foo.csv", "subdir2/foo.json"},
+		},
+		{
+			name:  "limit",
+			fsys:  sub.(*FS),
+			query: "foo",
+			opts:  SearchOptions{Limit: 2},
+			want:  []string{"subdir2/foo-json", "subdir2/foo.csv"},
+		},
+		{
+			name:  "special characters",
+			fsys:  fsys,
+			query: "it's",
+			want:  []string{"subdir1/it's here.txt"},
+		},
+		{
+			name:  "colon",
+			fsys:  fsys,
+			query: "foo:bar",
+		},
+		{
+			name:  "nothing",
+			fsys:  fsys,
+			query: "nothing",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := tt.fsys.Search(ctx, tt.query, tt.opts)
+			noErr(t, err)
+			var got []string
+			for _, result := range results {
+				got = append(got, result.Path)
+				info, err := tt.fsys.Stat(result.Path)
+				noErr(t, err)
+				assertEqual(t, info.Sys().(*Item).ID, result.Info.Sys().(*Item).ID, result.Path)
+				assertEqual(t, info.Name(), result.Info.Name(), result.Path)
+				assertEqual(t, info.Size(), result.Info.Size(), result.Path)
+			}
+			if !slices.Equal(tt.want, got) {
+				t.Errorf("want %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,269 @@
+package onedrivefs
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+)
+
+// The predefined sizes of the thumbnails of FS.OpenThumbnail, see also
+// CustomThumbnailSize.
+//
+// OneDrive API docs: https://learn.microsoft.com/en-us/graph/api/driveitem-list-thumbnails
+const (
+	// ThumbnailSmall fits into 96 pixels, cropped to a square.
+	ThumbnailSmall = "small"
+	// ThumbnailMedium fits into 176 pixels.
+	ThumbnailMedium = "medium"
+	// ThumbnailLarge fits into 800 pixels.
+	ThumbnailLarge = "large"
+)
+
+// CustomThumbnailSize returns the size of the thumbnails fitting into the
+// width and height, keeping the aspect ratio of the item unless cropped.
+func CustomThumbnailSize(width, height int, crop bool) string {
+	size := fmt.Sprintf("c%dx%d", width, height)
+	if crop {
+		size += "_crop"
+	}
+	return size
+}
+
+type thumbnail struct {
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	URL    string `json:"url"`
+	// Size is the size of the image in bytes, nil when the API doesn't
+	// describe it.
+	Size *int64 `json:"size"`
+}
+
+// OpenThumbnail opens the thumbnail image of the named item in the size, one
+// of the Thumbnail constants or CustomThumbnailSize. The image is streamed,
+// like the files opened by OpenAs. Its file info has the name of the item and
+// the size of the image, taken from the download when the API doesn't
+// describe it, and no modification time. The error matches fs.ErrNotExist
+// when the item has no thumbnail.
+//
+// OneDrive API docs: https://learn.microsoft.com/en-us/graph/api/driveitem-list-thumbnails#get-a-single-thumbnail
+func (f *FS) OpenThumbnail(name, size string) (fs.File, error) {
+	if err := validatePath(name); err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	if size == "" {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	itemPath := name
+	if name == "." {
+		itemPath = ""
+	}
+	req, err := f.newRequest("GET", f.itemPathURL(itemPath, "thumbnails/0/"+url.PathEscape(size)), nil)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	var thumb *thumbnail
+	if err := f.doRequest(f.ctx, req, &thumb); err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	file, err := f.openThumbnail(name, thumb)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return file, nil
+}
+
+// openThumbnail starts the download of the thumbnail of the named item.
+func (f *FS) openThumbnail(name string, thumb *thumbnail) (*streamFile, error) {
+	if thumb == nil || thumb.URL == "" {
+		return nil, fs.ErrNotExist
+	}
+	resp, err := f.getStream(thumb.URL)
+	if err != nil {
+		return nil, err
+	}
+	size := max(resp.ContentLength, 0)
+	if thumb.Size != nil {
+		size = *thumb.Size
+	}
+	return &streamFile{fileInfo: thumbnailInfo(name, size), body: resp.Body}, nil
+}
+
+// thumbnailInfo returns the file info of the thumbnail of the named item.
+func thumbnailInfo(name string, size int64) fileInfo {
+	return fileInfo{name: path.Base(name), size: size, mode: 0o555}
+}
+
+// Thumbnails returns a read-only FS of the thumbnails of the files in the
+// size, see OpenThumbnail. Its files are the thumbnails of the files at the
+// same paths, and its directories are the directories of f, listing just
+// the files having a thumbnail. The Info method of the directory entries of
+// the files downloads the thumbnail only when the listing doesn't describe
+// its size.
+func (f *FS) Thumbnails(size string) fs.FS {
+	return &thumbnailFS{fs: f, size: size}
+}
+
+type thumbnailFS struct {
+	fs   *FS
+	size string
+}
+
+var _ fs.FS = &thumbnailFS{}
+
+func (t *thumbnailFS) Open(name string) (fs.File, error) {
+	if err := validatePath(name); err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	if t.size == "" {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	itemPath := name
+	if name == "." {
+		itemPath = ""
+	}
+	req, err := t.fs.newRequest("GET", t.fs.itemPathURL(itemPath, ""), nil)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	req.URL.RawQuery = thumbnailsQuery(t.size)
+	var item *driveItem
+	if err := t.fs.doRequest(t.fs.ctx, req, &item); err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	if item.Folder != nil {
+		return &thumbnailDir{fileInfo: newFileInfo(item), fs: t, id: item.ID, path: name}, nil
+	}
+	file, err := t.fs.openThumbnail(name, item.thumbnail(t.size))
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return file, nil
+}
+
+// readDir lists the directories and the thumbnails of the files of the
+// folder.
+func (t *thumbnailFS) readDir(folderID, dir string) ([]fs.DirEntry, error) {
+	req, err := t.fs.newRequest("GET", t.fs.itemIDURL(folderID, "children"), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.URL.RawQuery = thumbnailsQuery(t.size)
+	var page *driveItemsResponse
+	if err := t.fs.doRequest(t.fs.ctx, req, &page); err != nil {
+		return nil, err
+	}
+	var entries []fs.DirEntry
+	for {
+		for _, item := range page.DriveItems {
+			if item.Folder != nil {
+				entries = append(entries, &dirEntry{fileInfo: newFileInfo(item)})
+			} else if thumb := item.thumbnail(t.size); thumb != nil {
+				entries = append(entries, &thumbnailEntry{fs: t.fs, path: path.Join(dir, item.Name), thumb: thumb})
+			}
+		}
+		if page.NextLink == "" {
+			break
+		}
+		if page, err = t.fs.listDriveItemsNextPage(t.fs.ctx, page.NextLink); err != nil {
+			return nil, err
+		}
+	}
+	slices.SortFunc(entries, func(a, b fs.DirEntry) int {
+		return strings.Compare(a.Name(), b.Name())
+	})
+	return entries, nil
+}
+
+// thumbnailsQuery returns the query parameters expanding the thumbnails of
+// the size of the items.
+//
+// OneDrive API docs: https://learn.microsoft.com/en-us/graph/api/driveitem-list-thumbnails#getting-thumbnails-while-listing-driveitems
+func thumbnailsQuery(size string) string {
+	return url.Values{"$expand": {"thumbnails($select=" + size + ")"}}.Encode()
+}
+
+// thumbnailSet is a set of the thumbnails of an item, keyed by their sizes.
+type thumbnailSet map[string]json.RawMessage
+
+// thumbnail returns the expanded thumbnail of the size, nil when the item
+// has none.
+func (d *driveItem) thumbnail(size string) *thumbnail {
+	for _, set := range d.Thumbnails {
+		var thumb *thumbnail
+		if err := json.Unmarshal(set[size], &thumb); err == nil && thumb != nil && thumb.URL != "" {
+			return thumb
+		}
+	}
+	return nil
+}
+
+// thumbnailDir is a directory opened in the FS of the thumbnails. Its
+// entries are listed by the first ReadDir.
+type thumbnailDir struct {
+	fileInfo
+	fs     *thumbnailFS
+	id     string
+	path   string
+	listed bool
+	items  []fs.DirEntry
+}
+
+var _ fs.ReadDirFile = &thumbnailDir{}
+
+func (d *thumbnailDir) Stat() (fs.FileInfo, error) { return &d.fileInfo, nil }
+
+func (d *thumbnailDir) ReadDir(count int) ([]fs.DirEntry, error) {
+	if !d.listed {
+		items, err := d.fs.readDir(d.id, d.path)
+		if err != nil {
+			return nil, &fs.PathError{Op: "readdir", Path: d.path, Err: err}
+		}
+		d.listed, d.items = true, items
+	}
+	n := len(d.items)
+	if n == 0 && count > 0 {
+		return nil, io.EOF
+	}
+	if count > 0 && n > count {
+		n = count
+	}
+	list := d.items[:n:n]
+	d.items = d.items[n:]
+	return list, nil
+}
+
+func (d *thumbnailDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: fs.ErrInvalid}
+}
+
+func (d *thumbnailDir) Close() error { return nil }
+
+// thumbnailEntry is a directory entry of a thumbnail. Info takes the size
+// from the listing, downloading the thumbnail only when it's missing.
+type thumbnailEntry struct {
+	fs    *FS
+	path  string
+	thumb *thumbnail
+}
+
+func (e *thumbnailEntry) Name() string      { return path.Base(e.path) }
+func (e *thumbnailEntry) IsDir() bool       { return false }
+func (e *thumbnailEntry) Type() fs.FileMode { return 0 }
+
+func (e *thumbnailEntry) Info() (fs.FileInfo, error) {
+	if e.thumb.Size != nil {
+		info := thumbnailInfo(e.path, *e.thumb.Size)
+		return &info, nil
+	}
+	file, err := e.fs.openThumbnail(e.path, e.thumb)
+	if err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: e.path, Err: err}
+	}
+	defer func() { _ = file.Close() }()
+	return file.Stat()
+}
@@ -0,0 +1,103 @@
+package onedrivefs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"go.dataddo.com/onedrivefs/onedrivefstest"
+)
+
+func TestFS_OpenThumbnail(t *testing.T) {
+	fsys, srv := initFakeFileSystem(t, onedrivefstest.Options{})
+	noErr(t, srv.WriteFile("photos/cat.jpg", []byte("meow")))
+
+	for _, size := range []string{ThumbnailSmall, ThumbnailMedium, ThumbnailLarge, CustomThumbnailSize(300, 200, true)} {
+		f, err := fsys.OpenThumbnail("photos/cat.jpg", size)
+		noErr(t, err, size)
+		content, err := io.ReadAll(f)
+		noErr(t, err, size)
+		noErr(t, f.Close(), size)
+		assertEqual(t, "\xff\xd8\xff fake "+size+" thumbnail of cat.jpg", string(content), size)
+		info, err := f.Stat()
+		noErr(t, err, size)
+		assertEqual(t, "cat.jpg", info.Name(), size)
+		assertEqual(t, int64(len(content)), info.Size(), size)
+	}
+	// The thumbnail is addressed by the path of the item.
+	srv.ResetRequests()
+	f, err := fsys.OpenThumbnail("photos/cat.jpg", ThumbnailSmall)
+	noErr(t, err)
+	noErr(t, f.Close())
+	assertEqual(t, 1, apiRequests(srv), "API requests")
+
+	_, err = fsys.OpenThumbnail("README.md", ThumbnailSmall)
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Error("expected fs.ErrNotExist, got:", err)
+	}
+	_, err = fsys.OpenThumbnail("photos/dog.jpg", ThumbnailSmall)
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Error("expected fs.ErrNotExist, got:", err)
+	}
+	_, err = fsys.OpenThumbnail("photos/cat.jpg", "huge")
+	var apiErr *OneDriveAPIError
+	if !errors.As(err, &apiErr) || apiErr.Code != InvalidRequestErrorCode {
+		t.Error("expected invalidRequest error, got:", err)
+	}
+}
+
+func TestFS_Thumbnails(t *testing.T) {
+	fsys, srv := initFakeFileSystem(t, onedrivefstest.Options{})
+	noErr(t, srv.WriteFile("photos/cat.jpg", []byte("meow")))
+	noErr(t, srv.WriteFile("photos/2024/dog.png", []byte("woof")))
+
+	thumbnails := fsys.Thumbnails(ThumbnailMedium)
+	var found []string
+	noErr(t, fs.WalkDir(thumbnails, "photos", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		content, err := fs.ReadFile(thumbnails, name)
+		noErr(t, err, name)
+		assertEqual(t, "\xff\xd8\xff fake medium thumbnail of "+d.Name(), string(content), name)
+		found = append(found, name)
+		return nil
+	}))
+	assertEqual(t, 2, len(found), "thumbnails")
+	_, err := fs.ReadFile(thumbnails, "README.md")
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Error("expected fs.ErrNotExist, got:", err)
+	}
+	// The files without a thumbnail aren't listed.
+	entries, err := fs.ReadDir(thumbnails, ".")
+	noErr(t, err)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	assertEqual(t, "photos subdir1", strings.Join(names, " "), "root entries")
+
+	// The info of the entries is described by the listing, without
+	// downloading the thumbnails.
+	srv.ResetRequests()
+	noErr(t, fs.WalkDir(thumbnails, "photos", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		noErr(t, err, name)
+		assertEqual(t, int64(len("\xff\xd8\xff fake medium thumbnail of "+d.Name())), info.Size(), name)
+		return nil
+	}))
+	for _, req := range srv.Requests() {
+		if strings.Contains(req, " /thumbnail/") {
+			t.Error("unexpected thumbnail download:", req)
+		}
+	}
+
+	noErr(t, fstest.TestFS(thumbnails, "photos/cat.jpg", "photos/2024/dog.png"))
+	noErr(t, fstest.TestFS(fsys.Thumbnails(CustomThumbnailSize(300, 200, true)), "photos/cat.jpg"))
+}
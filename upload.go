@@ -0,0 +1,232 @@
+package onedrivefs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultUploadSessionThreshold = 4 << 20
+	defaultUploadFragmentSize     = 10 << 20
+	// uploadFragmentUnit is the size the fragments must be a multiple of.
+	uploadFragmentUnit = 320 << 10
+)
+
+// UploadSession is a resumable upload of a file. Until it expires, an
+// interrupted upload can be resumed with FS.ResumeUpload, even from another
+// process, when the session is persisted.
+//
+// OneDrive API docs: https://learn.microsoft.com/en-us/graph/api/resources/uploadsession
+type UploadSession struct {
+	// Name is the name of the uploaded file, as passed to
+	// CreateUploadSession. It's used in the errors of ResumeUpload.
+	Name               string    `json:"name,omitempty"`
+	UploadURL          string    `json:"uploadUrl"`
+	ExpirationDateTime time.Time `json:"expirationDateTime"`
+	NextExpectedRanges []string  `json:"nextExpectedRanges"`
+}
+
+// CreateUploadSession starts a resumable upload of the named file, which
+// replaces the file when it exists. The content is uploaded with ResumeUpload.
+func (f *FS) CreateUploadSession(name string) (*UploadSession, error) {
+	if err := validateWritePath(name); err != nil {
+		return nil, &fs.PathError{Op: "upload", Path: name, Err: err}
+	}
+	session, err := f.createUploadSession(f.ctx, name, "replace")
+	if err != nil {
+		return nil, &fs.PathError{Op: "upload", Path: name, Err: err}
+	}
+	session.Name = name
+	return session, nil
+}
+
+// ResumeUpload uploads the size bytes long content read from r in the upload
+// session. Only the fragments the session still expects are sent, so it both
+// starts and resumes an upload. The content must be the same for all the
+// attempts.
+func (f *FS) ResumeUpload(session *UploadSession, r io.ReaderAt, size int64) error {
+	status, err := f.getUploadSession(f.ctx, session.UploadURL)
+	if err != nil {
+		return &fs.PathError{Op: "upload", Path: session.Name, Err: err}
+	}
+	item, err := f.uploadFragments(f.ctx, session.UploadURL, status.NextExpectedRanges, r, size)
+	if session.Name != "" {
+		f.invalidateCache(session.Name, item)
+	} else {
+		// The uploaded file is unknown, so any cached item may be stale.
+		f.cache.purge()
+	}
+	if err != nil {
+		return &fs.PathError{Op: "upload", Path: session.Name, Err: err}
+	}
+	return nil
+}
+
+// upload uploads the content of the file at itemPath, through an upload
+// session if the content is bigger than DriveOpts.UploadSessionThreshold.
+func (f *FS) upload(ctx context.Context, itemPath string, r io.ReaderAt, size int64, conflictBehavior string) (*driveItem, error) {
+	if size <= f.opts.UploadSessionThreshold {
+		content := make([]byte, size)
+		if _, err := r.ReadAt(content, 0); err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+		return f.uploadDriveItem(ctx, itemPath, content, conflictBehavior)
+	}
+	session, err := f.createUploadSession(ctx, itemPath, conflictBehavior)
+	if err != nil {
+		return nil, err
+	}
+	return f.uploadFragments(ctx, session.UploadURL, session.NextExpectedRanges, r, size)
+}
+
+// uploadFragments uploads the content in fragments, starting with the first
+// of the expected ranges. A failed fragment is retried from the range the
+// session expects next.
+func (f *FS) uploadFragments(ctx context.Context, uploadURL string, expectedRanges []string, r io.ReaderAt, size int64) (*driveItem, error) {
+	if size <= 0 {
+		return nil, errors.New("upload sessions can't upload empty files")
+	}
+	// The upload URL is pre-authenticated, so it's used with the download
+	// client.
+	client := f.opts.DownloadClient
+	offset, err := nextExpectedOffset(expectedRanges)
+	if err != nil {
+		return nil, err
+	}
+	for failures := 0; ; {
+		end := min(offset+f.opts.UploadFragmentSize, size)
+		item, session, err := f.uploadFragment(ctx, client, uploadURL, r, offset, end, size)
+		if err != nil {
+			failures++
+			if failures >= f.retry.MaxAttempts || ctx.Err() != nil {
+				return nil, err
+			}
+			// The fragment may have been received anyway, so ask the session.
+			status, statusErr := f.getUploadSession(ctx, uploadURL)
+			if statusErr != nil {
+				return nil, err
+			}
+			if len(status.NextExpectedRanges) == 0 {
+				continue
+			}
+			session = status
+		} else {
+			failures = 0
+		}
+		if item != nil {
+			return item, nil
+		}
+		if len(session.NextExpectedRanges) == 0 {
+			offset = end
+			continue
+		}
+		if offset, err = nextExpectedOffset(session.NextExpectedRanges); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// uploadFragment uploads the content between the start and end offsets. It
+// returns the uploaded item once the last fragment is received, the session
+// status otherwise.
+//
+// OneDrive API docs: https://learn.microsoft.com/en-us/graph/api/driveitem-createuploadsession#upload-bytes-to-the-upload-session
+func (f *FS) uploadFragment(ctx context.Context, client *http.Client, uploadURL string, r io.ReaderAt, start, end, size int64) (*driveItem, *UploadSession, error) {
+	req, err := http.NewRequest("PUT", uploadURL, io.NewSectionReader(r, start, end-start))
+	if err != nil {
+		return nil, nil, err
+	}
+	req.ContentLength = end - start
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(io.NewSectionReader(r, start, end-start)), nil
+	}
+	req.Header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end-1, size))
+	resp, err := f.retry.do(ctx, client, req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	switch resp.StatusCode {
+	case http.StatusAccepted:
+		var session *UploadSession
+		if err := json.NewDecoder(resp.Body).Decode(&session); err != nil {
+			return nil, nil, err
+		}
+		return nil, session, nil
+	case http.StatusOK, http.StatusCreated:
+		var item *driveItem
+		if err := json.NewDecoder(resp.Body).Decode(&item); err != nil {
+			return nil, nil, err
+		}
+		return item, nil, nil
+	default:
+		return nil, nil, responseError(resp)
+	}
+}
+
+// createUploadSession creates an upload session of the file at itemPath.
+//
+// OneDrive API docs: https://learn.microsoft.com/en-us/graph/api/driveitem-createuploadsession
+func (f *FS) createUploadSession(ctx context.Context, itemPath, conflictBehavior string) (*UploadSession, error) {
+	type item struct {
+		ConflictBehavior string `json:"@microsoft.graph.conflictBehavior"`
+	}
+	req, err := f.newJSONRequest("POST", f.itemPathURL(itemPath, "createUploadSession"), struct {
+		Item item `json:"item"`
+	}{
+		Item: item{ConflictBehavior: conflictBehavior},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var session *UploadSession
+	if err := f.doRequest(ctx, req, &session); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// getUploadSession gets the status of the upload session.
+//
+// OneDrive API docs: https://learn.microsoft.com/en-us/graph/api/driveitem-createuploadsession#resuming-an-in-progress-upload
+func (f *FS) getUploadSession(ctx context.Context, uploadURL string) (*UploadSession, error) {
+	req, err := http.NewRequest("GET", uploadURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := f.retry.do(ctx, f.opts.DownloadClient, req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode >= 400 {
+		return nil, responseError(resp)
+	}
+	var session *UploadSession
+	if err := json.NewDecoder(resp.Body).Decode(&session); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// nextExpectedOffset returns the start of the first range, formatted like
+// "1024-2047" or "1024-".
+func nextExpectedOffset(ranges []string) (int64, error) {
+	if len(ranges) == 0 {
+		return 0, nil
+	}
+	start, _, _ := strings.Cut(ranges[0], "-")
+	offset, err := strconv.ParseInt(start, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid expected range %q: %w", ranges[0], err)
+	}
+	return offset, nil
+}
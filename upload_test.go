@@ -0,0 +1,122 @@
+package onedrivefs
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"go.dataddo.com/onedrivefs/onedrivefstest"
+)
+
+func TestFS_uploadSession(t *testing.T) {
+	fsys, srv := initFakeFileSystem(t, onedrivefstest.Options{})
+	fsys.opts.UploadSessionThreshold = 1 << 10
+	fsys.opts.UploadFragmentSize = uploadFragmentUnit
+	content := bytes.Repeat([]byte("0123456789abcdef"), 64<<10) // 1 MiB
+
+	// The fragment upload fails once, so it has to be retried.
+	srv.InjectFault(onedrivefstest.Fault{
+		Match: func(r *http.Request) bool {
+			return r.Method == "PUT" && strings.HasPrefix(r.URL.Path, "/upload/")
+		},
+		Count:      1,
+		StatusCode: http.StatusInternalServerError,
+		Code:       GeneralExceptionErrorCode,
+	})
+	f, err := fsys.Create("big.bin")
+	noErr(t, err)
+	_, err = io.Copy(f, bytes.NewReader(content))
+	noErr(t, err)
+	noErr(t, f.Close())
+
+	got, err := srv.ReadFile("big.bin")
+	noErr(t, err)
+	assertEqual(t, true, bytes.Equal(content, got), "big.bin")
+	var fragments int
+	for _, req := range srv.Requests() {
+		if strings.HasPrefix(req, "PUT /upload/") {
+			fragments++
+		}
+	}
+	assertEqual(t, 5, fragments, "fragment uploads")
+}
+
+func TestFS_ResumeUpload(t *testing.T) {
+	fsys, srv := initFakeFileSystem(t, onedrivefstest.Options{})
+	fsys.opts.UploadFragmentSize = uploadFragmentUnit
+	content := bytes.Repeat([]byte("x"), 2*uploadFragmentUnit+100)
+
+	session, err := fsys.CreateUploadSession("subdir1/resumed.bin")
+	noErr(t, err)
+	// Interrupt the upload after the first fragment.
+	srv.InjectFault(onedrivefstest.Fault{
+		Match: func(r *http.Request) bool {
+			return r.Method == "PUT" && strings.Contains(r.Header.Get("Content-Range"), "bytes 327680-")
+		},
+		StatusCode: http.StatusInternalServerError,
+		Code:       GeneralExceptionErrorCode,
+	})
+	err = fsys.ResumeUpload(session, bytes.NewReader(content), int64(len(content)))
+	var pathErr *fs.PathError
+	if !errors.As(err, &pathErr) || pathErr.Op != "upload" || pathErr.Path != "subdir1/resumed.bin" {
+		t.Fatal("expected upload *fs.PathError, got:", err)
+	}
+
+	// Resume with a fresh FS, knowing just the upload URL.
+	fsys, err = OpenFS(srv.Client(), DriveOpts{Endpoint: srv.Endpoint(), UploadFragmentSize: uploadFragmentUnit})
+	noErr(t, err)
+	srv.ClearFaults()
+	err = fsys.ResumeUpload(&UploadSession{UploadURL: session.UploadURL}, bytes.NewReader(content), int64(len(content)))
+	noErr(t, err)
+	got, err := srv.ReadFile("subdir1/resumed.bin")
+	noErr(t, err)
+	assertEqual(t, true, bytes.Equal(content, got), "subdir1/resumed.bin")
+}
+
+func TestFS_uploadSpool(t *testing.T) {
+	fsys, srv := initFakeFileSystem(t, onedrivefstest.Options{})
+	fsys.opts.UploadSessionThreshold = 1 << 10
+
+	// Content up to the threshold is kept in memory and uploaded at once.
+	f, err := fsys.Create("small.bin")
+	noErr(t, err)
+	_, err = f.Write(make([]byte, 1<<10))
+	noErr(t, err)
+	assertEqual(t, (*os.File)(nil), f.(*writeFile).spool, "small.bin spool")
+	noErr(t, f.Close())
+
+	// Content above the threshold is spooled to a temporary file, removed
+	// once uploaded.
+	f, err = fsys.Create("big.bin")
+	noErr(t, err)
+	content := bytes.Repeat([]byte("0123456789abcdef"), 1<<7) // 2 KiB
+	for chunk := range slices.Chunk(content, 300) {
+		_, err = f.Write(chunk)
+		noErr(t, err)
+	}
+	spool := f.(*writeFile).spool
+	if spool == nil {
+		t.Fatal("expected the content to be spooled")
+	}
+	noErr(t, f.Close())
+	if _, err := os.Stat(spool.Name()); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatal("expected the spool to be removed, got:", err)
+	}
+
+	got, err := srv.ReadFile("big.bin")
+	noErr(t, err)
+	assertEqual(t, true, bytes.Equal(content, got), "big.bin")
+	var sessions int
+	for _, req := range srv.Requests() {
+		if strings.HasPrefix(req, "POST ") && strings.Contains(req, "/createUploadSession") {
+			sessions++
+		}
+	}
+	assertEqual(t, 1, sessions, "upload sessions")
+}
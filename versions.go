@@ -0,0 +1,158 @@
+package onedrivefs
+
+import (
+	"context"
+	"io/fs"
+	"net/url"
+	"time"
+)
+
+// Version is a version of a file. OneDrive keeps the previous versions of
+// the files when their content is replaced.
+//
+// OneDrive API docs: https://learn.microsoft.com/en-us/graph/api/resources/driveitemversion
+type Version struct {
+	// ID identifies the version of the file, e.g. "3.0".
+	ID                   string
+	Size                 int64
+	LastModifiedDateTime time.Time
+	LastModifiedBy       IdentitySet
+}
+
+type driveItemVersion struct {
+	ID                   string         `json:"id"`
+	Size                 int64          `json:"size"`
+	LastModifiedDateTime dateTimeOffset `json:"lastModifiedDateTime"`
+	LastModifiedBy       IdentitySet    `json:"lastModifiedBy"`
+}
+
+func (v *driveItemVersion) version() Version {
+	return Version{
+		ID:                   v.ID,
+		Size:                 v.Size,
+		LastModifiedDateTime: time.Time(v.LastModifiedDateTime),
+		LastModifiedBy:       v.LastModifiedBy,
+	}
+}
+
+type driveItemVersionsResponse struct {
+	Versions []*driveItemVersion `json:"value"`
+	NextLink string              `json:"@odata.nextLink"`
+}
+
+// Versions returns the versions of the named file, the current one first.
+//
+// OneDrive API docs: https://learn.microsoft.com/en-us/graph/api/driveitem-list-versions
+func (f *FS) Versions(name string) ([]Version, error) {
+	item, err := f.fileItem("versions", name)
+	if err != nil {
+		return nil, err
+	}
+	versions, err := f.listVersions(f.ctx, item.ID)
+	if err != nil {
+		return nil, &fs.PathError{Op: "versions", Path: name, Err: err}
+	}
+	return versions, nil
+}
+
+// listVersions lists the versions of the file of the ID, following the
+// pages of the listing.
+func (f *FS) listVersions(ctx context.Context, itemID string) ([]Version, error) {
+	var versions []Version
+	for apiURL := f.itemIDURL(itemID, "versions"); apiURL != ""; {
+		req, err := f.newRequest("GET", apiURL, nil)
+		if err != nil {
+			return nil, err
+		}
+		var page *driveItemVersionsResponse
+		if err := f.doRequest(ctx, req, &page); err != nil {
+			return nil, err
+		}
+		for _, v := range page.Versions {
+			versions = append(versions, v.version())
+		}
+		apiURL = page.NextLink
+	}
+	return versions, nil
+}
+
+// OpenVersion opens the version of the named file for reading. The Sys
+// method of its fs.FileInfo returns the metadata of the file, with the size
+// and the modification of the version.
+//
+// OneDrive API docs: https://learn.microsoft.com/en-us/graph/api/driveitemversion-get-contents
+func (f *FS) OpenVersion(name, versionID string) (fs.File, error) {
+	item, err := f.fileItem("open", name)
+	if err != nil {
+		return nil, err
+	}
+	req, err := f.newRequest("GET", f.versionURL(item.ID, versionID, ""), nil)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	var v *driveItemVersion
+	if err := f.doRequest(f.ctx, req, &v); err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	downloadURL, err := f.versionDownloadURL(f.ctx, item.ID, versionID)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return &openFile{
+		fileInfo:    versionFileInfo(newFileInfo(item), v.version()),
+		fs:          f,
+		client:      f.opts.DownloadClient,
+		downloadURL: downloadURL,
+		version:     versionID,
+	}, nil
+}
+
+// RestoreVersion makes the version of the named file its current version.
+// The replaced content is kept as a new version.
+//
+// OneDrive API docs: https://learn.microsoft.com/en-us/graph/api/driveitemversion-restore
+func (f *FS) RestoreVersion(name, versionID string) error {
+	item, err := f.fileItem("restore", name)
+	if err != nil {
+		return err
+	}
+	req, err := f.newRequest("POST", f.versionURL(item.ID, versionID, "restoreVersion"), nil)
+	if err != nil {
+		return &fs.PathError{Op: "restore", Path: name, Err: err}
+	}
+	defer f.invalidateCache(name, item)
+	if err := f.doRequest(f.ctx, req, nil); err != nil {
+		return &fs.PathError{Op: "restore", Path: name, Err: err}
+	}
+	return nil
+}
+
+// versionFileInfo returns the file info of the version of the file.
+func versionFileInfo(f fileInfo, v Version) fileInfo {
+	f.size = v.Size
+	f.modTime = v.LastModifiedDateTime
+	if f.sys != nil {
+		sys := *f.sys
+		sys.Size = v.Size
+		sys.LastModifiedDateTime = v.LastModifiedDateTime
+		sys.LastModifiedBy = v.LastModifiedBy
+		// The hashes and the tags describe the current version only.
+		sys.Hashes = Hashes{}
+		sys.ETag, sys.CTag = "", ""
+		f.sys = &sys
+	}
+	return f
+}
+
+func (f *FS) versionURL(itemID, versionID, action string) string {
+	versionAction := "versions/" + url.PathEscape(versionID)
+	if action != "" {
+		versionAction += "/" + action
+	}
+	return f.itemIDURL(itemID, versionAction)
+}
+
+// versionDownloadURL gets the pre-authenticated download URL of the version.
+func (f *FS) versionDownloadURL(ctx context.Context, itemID, versionID string) (string, error) {
+	return f.contentLocation(ctx, f.versionURL(itemID, versionID, "content"))
+}
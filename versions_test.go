@@ -0,0 +1,67 @@
+package onedrivefs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"syscall"
+	"testing"
+
+	"go.dataddo.com/onedrivefs/onedrivefstest"
+)
+
+func TestFS_Versions(t *testing.T) {
+	fsys, srv := initFakeFileSystem(t, onedrivefstest.Options{})
+	original := "This is a test dir for onedrivefs"
+	noErr(t, fsys.WriteFile("README.md", []byte("second"), 0o644))
+	noErr(t, fsys.WriteFile("README.md", []byte("the third one"), 0o644))
+
+	versions, err := fsys.Versions("README.md")
+	noErr(t, err)
+	assertEqual(t, 3, len(versions), "versions")
+	for i, want := range []int64{int64(len("the third one")), int64(len("second")), int64(len(original))} {
+		assertEqual(t, want, versions[i].Size, versions[i].ID)
+	}
+
+	oldest := versions[2]
+	f, err := fsys.OpenVersion("README.md", oldest.ID)
+	noErr(t, err)
+	t.Cleanup(func() { _ = f.Close() })
+	info, err := f.Stat()
+	noErr(t, err)
+	assertEqual(t, oldest.Size, info.Size(), "size")
+	assertEqual(t, true, oldest.LastModifiedDateTime.Equal(info.ModTime()), "modification time")
+	// The download URL of the version is refreshed when it expires.
+	srv.ExpireDownloadURLs()
+	content, err := io.ReadAll(f)
+	noErr(t, err)
+	assertEqual(t, original, string(content), "content")
+
+	noErr(t, fsys.RestoreVersion("README.md", oldest.ID))
+	content, err = fsys.ReadFile("README.md")
+	noErr(t, err)
+	assertEqual(t, original, string(content), "restored content")
+	versions, err = fsys.Versions("README.md")
+	noErr(t, err)
+	assertEqual(t, 4, len(versions), "versions")
+}
+
+func TestFS_Versions_errors(t *testing.T) {
+	fsys, _ := initFakeFileSystem(t, onedrivefstest.Options{})
+	_, err := fsys.Versions("missing")
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Error("expected fs.ErrNotExist, got:", err)
+	}
+	_, err = fsys.Versions("subdir1")
+	if !errors.Is(err, syscall.EISDIR) {
+		t.Error("expected EISDIR, got:", err)
+	}
+	_, err = fsys.OpenVersion("README.md", "99.0")
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Error("expected fs.ErrNotExist, got:", err)
+	}
+	err = fsys.RestoreVersion("README.md", "99.0")
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Error("expected fs.ErrNotExist, got:", err)
+	}
+}
@@ -0,0 +1,318 @@
+package onedrivefs
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Op is the kind of a change reported by Watcher.
+type Op uint32
+
+const (
+	// Create means that a file or a directory was created or moved into the
+	// watched directory.
+	Create Op = 1 << iota
+	// Write means that the content of a file changed.
+	Write
+	// Remove means that a file or a directory was removed or moved out of the
+	// watched directory.
+	Remove
+	// Rename means that a file or a directory was renamed or moved within the
+	// watched directory.
+	Rename
+)
+
+func (op Op) String() string {
+	var names []string
+	for _, o := range []struct {
+		op   Op
+		name string
+	}{{Create, "CREATE"}, {Write, "WRITE"}, {Remove, "REMOVE"}, {Rename, "RENAME"}} {
+		if op&o.op != 0 {
+			names = append(names, o.name)
+		}
+	}
+	return strings.Join(names, "|")
+}
+
+// Event is a change of a file or a directory reported by Watcher.
+type Event struct {
+	Op Op
+	// Path is the slash separated path of the item, the same as accepted by
+	// Open.
+	Path string
+	// OldPath is the path before the Rename.
+	OldPath string
+	IsDir   bool
+	// Item is the metadata of the item. It's the last known metadata for
+	// Remove.
+	Item *Item
+}
+
+// defaultWatchInterval is the polling interval of Watch when none is given.
+const defaultWatchInterval = time.Minute
+
+// Watcher reports the changes in a directory tree, see FS.Watch.
+type Watcher struct {
+	fs       *FS
+	root     string
+	interval time.Duration
+	events   chan Event
+	errs     chan error
+
+	token string
+	// items maps the IDs of the watched items to their last known state.
+	items map[string]*watchedItem
+}
+
+type watchedItem struct {
+	path  string
+	isDir bool
+	item  *Item
+}
+
+// Watch watches the directory tree rooted at root, the whole FS for ".",
+// and reports its changes through the Events channel. It polls the changes of
+// the drive every interval, 1 minute when the interval is not positive.
+//
+// All the changes of an item between two polls are reported as a single
+// event, e.g. a file created and then written is reported by Create only.
+// Items created and removed between two polls are not reported at all.
+//
+// Errors of the polls, such as network failures, are reported through the
+// Errors channel and the watching continues with the next poll. When OneDrive
+// requires a resync, the tree is listed again and the differences are
+// reported as events. The watching stops and the channels are closed when the
+// context is done.
+func (f *FS) Watch(ctx context.Context, root string, interval time.Duration) (*Watcher, error) {
+	if err := validatePath(root); err != nil {
+		return nil, &fs.PathError{Op: "watch", Path: root, Err: err}
+	}
+	if interval <= 0 {
+		interval = defaultWatchInterval
+	}
+	w := &Watcher{
+		fs:       f,
+		root:     root,
+		interval: interval,
+		events:   make(chan Event),
+		errs:     make(chan error, 1),
+	}
+	token, items, err := w.scan(ctx)
+	if err != nil {
+		return nil, &fs.PathError{Op: "watch", Path: root, Err: err}
+	}
+	w.token, w.items = token, items
+	go w.run(ctx)
+	return w, nil
+}
+
+// Events returns the channel of the changes. It's closed when the context of
+// Watch is done.
+func (w *Watcher) Events() <-chan Event {
+	return w.events
+}
+
+// Errors returns the channel of the errors of the polls. The errors occurring
+// while the previous one wasn't received are dropped. It's closed when the
+// context of Watch is done.
+func (w *Watcher) Errors() <-chan error {
+	return w.errs
+}
+
+func (w *Watcher) run(ctx context.Context) {
+	defer close(w.errs)
+	defer close(w.events)
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		events, err := w.poll(ctx)
+		for _, event := range events {
+			select {
+			case w.events <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+		if err != nil && ctx.Err() == nil {
+			select {
+			case w.errs <- err:
+			default:
+			}
+		}
+	}
+}
+
+// poll returns the events since the previous poll. On failure, the events
+// found so far are returned along with the error. The next poll repeats the
+// query from the same token, and the already reported changes don't make
+// events again.
+func (w *Watcher) poll(ctx context.Context) ([]Event, error) {
+	changes := w.fs.Changes(ctx, w.token)
+	var events []Event
+	for changes.Next() {
+		change := changes.Change()
+		if change.Type == ChangeDeleted || !w.inScope(change.Path) {
+			events = append(events, w.update(change.Item.ID, nil)...)
+			continue
+		}
+		item := &watchedItem{
+			path:  change.Path,
+			isDir: change.IsDir,
+			item:  change.Item,
+		}
+		if _, known := w.items[change.Item.ID]; known || !change.IsDir {
+			events = append(events, w.update(change.Item.ID, item)...)
+			continue
+		}
+		// The content of a directory moved in from outside of the watched
+		// tree isn't reported by the delta query, it's listed instead.
+		content := map[string]*watchedItem{}
+		if err := w.walk(ctx, change.Path, content); err != nil {
+			return events, err
+		}
+		events = append(events, w.update(change.Item.ID, item)...)
+		events = append(events, w.updateAll(content)...)
+	}
+	if err := changes.Err(); err != nil {
+		if resyncErr := (&ResyncRequiredError{}); errors.As(err, &resyncErr) {
+			resyncEvents, err := w.resync(ctx)
+			return append(events, resyncEvents...), err
+		}
+		return events, err
+	}
+	w.token = changes.Token()
+	return events, nil
+}
+
+// resync lists the tree again and returns the differences from the known
+// state as events.
+func (w *Watcher) resync(ctx context.Context) ([]Event, error) {
+	token, items, err := w.scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var events []Event
+	for id := range w.items {
+		if _, ok := items[id]; !ok {
+			events = append(events, w.update(id, nil)...)
+		}
+	}
+	events = append(events, w.updateAll(items)...)
+	w.token = token
+	return events, nil
+}
+
+// updateAll records the new state of the items, the parents before their
+// children, and returns the resulting events.
+func (w *Watcher) updateAll(items map[string]*watchedItem) []Event {
+	ids := slices.SortedFunc(maps.Keys(items), func(a, b string) int {
+		return strings.Compare(items[a].path, items[b].path)
+	})
+	var events []Event
+	for _, id := range ids {
+		events = append(events, w.update(id, items[id])...)
+	}
+	return events
+}
+
+// update records the new state of the item, nil for removed items, and
+// returns the resulting events.
+func (w *Watcher) update(id string, item *watchedItem) []Event {
+	old, known := w.items[id]
+	switch {
+	case item == nil && !known:
+		return nil
+	case item == nil:
+		delete(w.items, id)
+		if old.isDir {
+			w.forEachDescendant(old.path, func(id string, _ *watchedItem) { delete(w.items, id) })
+		}
+		return []Event{{Op: Remove, Path: old.path, IsDir: old.isDir, Item: old.item}}
+	case !known:
+		w.items[id] = item
+		return []Event{{Op: Create, Path: item.path, IsDir: item.isDir, Item: item.item}}
+	}
+	w.items[id] = item
+	var op Op
+	if item.path != old.path {
+		op |= Rename
+		if item.isDir {
+			w.forEachDescendant(old.path, func(_ string, descendant *watchedItem) {
+				descendant.path = item.path + strings.TrimPrefix(descendant.path, old.path)
+			})
+		}
+	}
+	if !item.isDir && item.item.CTag != old.item.CTag {
+		op |= Write
+	}
+	if op == 0 {
+		return nil
+	}
+	event := Event{Op: op, Path: item.path, IsDir: item.isDir, Item: item.item}
+	if op&Rename != 0 {
+		event.OldPath = old.path
+	}
+	return []Event{event}
+}
+
+func (w *Watcher) forEachDescendant(dirPath string, fn func(id string, item *watchedItem)) {
+	for id, item := range w.items {
+		if strings.HasPrefix(item.path, dirPath+"/") {
+			fn(id, item)
+		}
+	}
+}
+
+// scan lists the watched tree. The returned token precedes the listing, so
+// the changes made during it are reported by the next poll.
+func (w *Watcher) scan(ctx context.Context) (string, map[string]*watchedItem, error) {
+	token, err := w.fs.LatestChangeToken(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+	items := map[string]*watchedItem{}
+	if err := w.walk(ctx, w.root, items); err != nil {
+		return "", nil, err
+	}
+	return token, items, nil
+}
+
+// walk adds the items of the tree rooted at root, excluding the root itself.
+func (w *Watcher) walk(ctx context.Context, root string, items map[string]*watchedItem) error {
+	return fs.WalkDir(w.fs.Context(ctx), root, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if name == root {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		item, ok := info.Sys().(*Item)
+		if !ok {
+			return nil
+		}
+		items[item.ID] = &watchedItem{path: name, isDir: d.IsDir(), item: item}
+		return nil
+	})
+}
+
+func (w *Watcher) inScope(name string) bool {
+	if name == "" {
+		return false
+	}
+	return w.root == "." || strings.HasPrefix(name, w.root+"/")
+}
@@ -0,0 +1,71 @@
+package onedrivefs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.dataddo.com/onedrivefs/onedrivefstest"
+)
+
+func TestFS_Watch(t *testing.T) {
+	fsys, srv := initFakeFileSystem(t, onedrivefstest.Options{})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	w, err := fsys.Watch(ctx, "subdir1", 10*time.Millisecond)
+	noErr(t, err)
+
+	next := func() Event {
+		t.Helper()
+		select {
+		case event := <-w.Events():
+			return event
+		case err := <-w.Errors():
+			t.Fatal("unexpected error:", err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("no event")
+		}
+		return Event{}
+	}
+	requireEvent := func(want Event) {
+		t.Helper()
+		got := next()
+		if got.Op != want.Op || got.Path != want.Path || got.OldPath != want.OldPath || got.IsDir != want.IsDir {
+			t.Fatalf("want event %v %q %q dir=%v, got %v %q %q dir=%v",
+				want.Op, want.Path, want.OldPath, want.IsDir, got.Op, got.Path, got.OldPath, got.IsDir)
+		}
+		if got.Item == nil || got.Item.ID == "" {
+			t.Fatalf("event of %q: missing item", got.Path)
+		}
+	}
+
+	noErr(t, fsys.WriteFile("subdir1/new.txt", []byte("new"), 0o644))
+	requireEvent(Event{Op: Create, Path: "subdir1/new.txt"})
+
+	noErr(t, srv.WriteFile("subdir1/subdir2/foo.json", []byte(`"changed"`)))
+	requireEvent(Event{Op: Write, Path: "subdir1/subdir2/foo.json"})
+
+	noErr(t, fsys.Rename("subdir1/subdir2", "subdir1/renamed"))
+	requireEvent(Event{Op: Rename, Path: "subdir1/renamed", OldPath: "subdir1/subdir2", IsDir: true})
+
+	noErr(t, fsys.Remove("subdir1/renamed/foo-json"))
+	requireEvent(Event{Op: Remove, Path: "subdir1/renamed/foo-json"})
+
+	// Changes outside of the watched tree are not reported.
+	noErr(t, fsys.WriteFile("README.md", []byte("changed"), 0o644))
+	noErr(t, srv.WriteFile("moved/inner.txt", []byte("inner")))
+	noErr(t, fsys.Rename("moved", "subdir1/moved"))
+	requireEvent(Event{Op: Create, Path: "subdir1/moved", IsDir: true})
+	requireEvent(Event{Op: Create, Path: "subdir1/moved/inner.txt"})
+
+	noErr(t, fsys.Rename("subdir1/renamed/foo.csv", "foo.csv"))
+	requireEvent(Event{Op: Remove, Path: "subdir1/renamed/foo.csv"})
+
+	srv.ExpireDeltaTokens()
+	noErr(t, srv.RemoveAll("subdir1/new.txt"))
+	requireEvent(Event{Op: Remove, Path: "subdir1/new.txt"})
+
+	cancel()
+	for range w.Events() {
+	}
+}
@@ -0,0 +1,337 @@
+package onedrivefs
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"syscall"
+	"time"
+)
+
+// WritableFile is a file opened for writing by FS.Create or FS.OpenFile. The
+// written content is uploaded when the file is closed. Content bigger than
+// DriveOpts.UploadSessionThreshold is buffered in a temporary file and
+// uploaded through an upload session.
+type WritableFile interface {
+	fs.File
+	io.Writer
+}
+
+// Create creates or truncates the named file, similar to os.Create, except
+// that the file is opened only for writing. The parent directory must exist.
+func (f *FS) Create(name string) (WritableFile, error) {
+	file, err := f.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o666)
+	if err != nil {
+		return nil, err
+	}
+	return file.(WritableFile), nil
+}
+
+// OpenFile is the generalized open call, similar to os.OpenFile. Without
+// os.O_WRONLY or os.O_RDWR it opens the file for reading like Open, otherwise
+// it returns a WritableFile.
+//
+// OneDrive can't modify a part of a file, so a file opened for writing is
+// always written from scratch. An existing file must be opened with
+// os.O_TRUNC, and os.O_APPEND is not supported. The permission bits are
+// ignored.
+func (f *FS) OpenFile(name string, flag int, perm fs.FileMode) (fs.File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR) == 0 {
+		return f.Open(name)
+	}
+	if err := validateWritePath(name); err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	if flag&os.O_APPEND != 0 {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: errors.ErrUnsupported}
+	}
+	conflictBehavior := "replace"
+	item, err := f.getDriveItemsByPath(f.ctx, name)
+	switch {
+	case err == nil:
+		if flag&os.O_CREATE != 0 && flag&os.O_EXCL != 0 {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrExist}
+		}
+		if item.Folder != nil {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: syscall.EISDIR}
+		}
+		// Keeping the content would need it to be downloaded and uploaded
+		// again, while writing from scratch would silently truncate it.
+		if flag&os.O_TRUNC == 0 {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: errors.ErrUnsupported}
+		}
+	case errors.Is(err, fs.ErrNotExist):
+		if flag&os.O_CREATE == 0 {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+		}
+		if _, err := f.getParentFolder(name); err != nil {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+		}
+		if flag&os.O_EXCL != 0 {
+			conflictBehavior = "fail"
+		}
+	default:
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return &writeFile{
+		fs:               f,
+		name:             name,
+		conflictBehavior: conflictBehavior,
+	}, nil
+}
+
+// WriteFile writes data to the named file, creating it if necessary, similar
+// to os.WriteFile. The permission bits are ignored.
+func (f *FS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	file, err := f.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := file.(WritableFile).Write(data); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Mkdir creates a new directory, similar to os.Mkdir. The parent directory
+// must exist. The permission bits are ignored.
+func (f *FS) Mkdir(name string, perm fs.FileMode) error {
+	if err := validateWritePath(name); err != nil {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: err}
+	}
+	parent, err := f.getParentFolder(name)
+	if err != nil {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: err}
+	}
+	folder, err := f.createFolder(f.ctx, parent.ID, path.Base(name))
+	f.invalidateCache(name, folder)
+	if err != nil {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: err}
+	}
+	return nil
+}
+
+// MkdirAll creates a directory along with any necessary parents, similar to
+// os.MkdirAll. The permission bits are ignored.
+func (f *FS) MkdirAll(name string, perm fs.FileMode) error {
+	if err := validatePath(name); err != nil {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: err}
+	}
+	info, err := f.Stat(name)
+	switch {
+	case err == nil:
+		if info.IsDir() {
+			return nil
+		}
+		return &fs.PathError{Op: "mkdir", Path: name, Err: syscall.ENOTDIR}
+	case !errors.Is(err, fs.ErrNotExist):
+		return &fs.PathError{Op: "mkdir", Path: name, Err: err}
+	}
+	if parent := path.Dir(name); parent != "." {
+		if err := f.MkdirAll(parent, perm); err != nil {
+			return err
+		}
+	}
+	if err := f.Mkdir(name, perm); err != nil {
+		// The directory may have been created concurrently.
+		if info, statErr := f.Stat(name); statErr == nil && info.IsDir() {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+// Remove removes the named file or empty directory, similar to os.Remove.
+func (f *FS) Remove(name string) error {
+	if err := validateWritePath(name); err != nil {
+		return &fs.PathError{Op: "remove", Path: name, Err: err}
+	}
+	item, err := f.getFreshDriveItemByPath(f.ctx, name)
+	if err != nil {
+		return &fs.PathError{Op: "remove", Path: name, Err: err}
+	}
+	if item.Folder != nil && item.Folder.ChildCount > 0 {
+		return &fs.PathError{Op: "remove", Path: name, Err: syscall.ENOTEMPTY}
+	}
+	defer f.invalidateCache(name, item)
+	if err := f.deleteDriveItem(f.ctx, item.ID); err != nil {
+		return &fs.PathError{Op: "remove", Path: name, Err: err}
+	}
+	return nil
+}
+
+// RemoveAll removes the named file or directory with everything it contains,
+// similar to os.RemoveAll. It returns nil when the path doesn't exist.
+func (f *FS) RemoveAll(name string) error {
+	if err := validateWritePath(name); err != nil {
+		return &fs.PathError{Op: "removeall", Path: name, Err: err}
+	}
+	item, err := f.getDriveItemsByPath(f.ctx, name)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return &fs.PathError{Op: "removeall", Path: name, Err: err}
+	}
+	defer f.invalidateCache(name, item)
+	if err := f.deleteDriveItem(f.ctx, item.ID); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return &fs.PathError{Op: "removeall", Path: name, Err: err}
+	}
+	return nil
+}
+
+// Rename renames (moves) oldname to newname, similar to os.Rename. An existing
+// file at newname is replaced, an existing directory is not.
+func (f *FS) Rename(oldname, newname string) error {
+	if err := validateWritePath(oldname); err != nil {
+		return &fs.PathError{Op: "rename", Path: oldname, Err: err}
+	}
+	if err := validateWritePath(newname); err != nil {
+		return &fs.PathError{Op: "rename", Path: newname, Err: err}
+	}
+	item, err := f.getFreshDriveItemByPath(f.ctx, oldname)
+	if err != nil {
+		return &fs.PathError{Op: "rename", Path: oldname, Err: err}
+	}
+	if oldname == newname {
+		return nil
+	}
+	replace := false
+	target, err := f.getFreshDriveItemByPath(f.ctx, newname)
+	switch {
+	case err == nil:
+		if target.Folder != nil || item.Folder != nil {
+			return &fs.PathError{Op: "rename", Path: newname, Err: fs.ErrExist}
+		}
+		replace = true
+	case !errors.Is(err, fs.ErrNotExist):
+		return &fs.PathError{Op: "rename", Path: newname, Err: err}
+	}
+	parent, err := f.getParentFolder(newname)
+	if err != nil {
+		return &fs.PathError{Op: "rename", Path: newname, Err: err}
+	}
+	defer f.invalidateCache(newname, target)
+	defer f.invalidateCache(oldname, item)
+	if _, err := f.moveDriveItem(f.ctx, item.ID, parent.ID, path.Base(newname), replace); err != nil {
+		return &fs.PathError{Op: "rename", Path: oldname, Err: err}
+	}
+	return nil
+}
+
+// getParentFolder returns the folder containing the named item. It fails when
+// the parent doesn't exist or isn't a folder.
+func (f *FS) getParentFolder(name string) (*driveItem, error) {
+	parentPath := path.Dir(name)
+	if parentPath == "." {
+		parentPath = ""
+	}
+	parent, err := f.getDriveItemsByPath(f.ctx, parentPath)
+	if err != nil {
+		return nil, err
+	}
+	if parent.Folder == nil {
+		return nil, syscall.ENOTDIR
+	}
+	return parent, nil
+}
+
+// validateWritePath validates the path like validatePath, but additionally
+// refuses the root directory, which can't be written to.
+func validateWritePath(name string) error {
+	if err := validatePath(name); err != nil {
+		return err
+	}
+	if name == "." || name == "" {
+		return fs.ErrInvalid
+	}
+	return nil
+}
+
+type writeFile struct {
+	fs               *FS
+	name             string
+	conflictBehavior string
+
+	buf bytes.Buffer
+	// spool holds the content once it's bigger than the upload session
+	// threshold.
+	spool  *os.File
+	size   int64
+	closed bool
+}
+
+var (
+	_ fs.File      = &writeFile{}
+	_ WritableFile = &writeFile{}
+)
+
+func (w *writeFile) Stat() (fs.FileInfo, error) {
+	return &fileInfo{
+		name:    path.Base(w.name),
+		size:    w.size,
+		mode:    0o666,
+		modTime: time.Now(),
+	}, nil
+}
+
+func (w *writeFile) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: w.name, Err: errors.ErrUnsupported}
+}
+
+func (w *writeFile) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, &fs.PathError{Op: "write", Path: w.name, Err: fs.ErrClosed}
+	}
+	if w.spool == nil && w.size+int64(len(p)) > w.fs.opts.UploadSessionThreshold {
+		spool, err := os.CreateTemp("", "onedrivefs-upload-*")
+		if err != nil {
+			return 0, &fs.PathError{Op: "write", Path: w.name, Err: err}
+		}
+		w.spool = spool
+		if _, err := w.buf.WriteTo(spool); err != nil {
+			return 0, &fs.PathError{Op: "write", Path: w.name, Err: err}
+		}
+	}
+	var (
+		n   int
+		err error
+	)
+	if w.spool != nil {
+		n, err = w.spool.Write(p)
+	} else {
+		n, err = w.buf.Write(p)
+	}
+	w.size += int64(n)
+	if err != nil {
+		return n, &fs.PathError{Op: "write", Path: w.name, Err: err}
+	}
+	return n, nil
+}
+
+// Close uploads the written content.
+func (w *writeFile) Close() error {
+	if w.closed {
+		return &fs.PathError{Op: "close", Path: w.name, Err: fs.ErrClosed}
+	}
+	w.closed = true
+	var content io.ReaderAt = bytes.NewReader(w.buf.Bytes())
+	if w.spool != nil {
+		defer func() {
+			_ = w.spool.Close()
+			_ = os.Remove(w.spool.Name())
+		}()
+		content = w.spool
+	}
+	item, err := w.fs.upload(w.fs.ctx, w.name, content, w.size, w.conflictBehavior)
+	w.fs.invalidateCache(w.name, item)
+	if err != nil {
+		return &fs.PathError{Op: "close", Path: w.name, Err: err}
+	}
+	return nil
+}
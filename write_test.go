@@ -0,0 +1,204 @@
+package onedrivefs
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"syscall"
+	"testing"
+
+	"go.dataddo.com/onedrivefs/onedrivefstest"
+)
+
+func TestFS_WriteFile(t *testing.T) {
+	fsys, srv := initFakeFileSystem(t, onedrivefstest.Options{})
+
+	noErr(t, fsys.WriteFile("subdir1/new.txt", []byte("new"), 0o644))
+	got, err := srv.ReadFile("subdir1/new.txt")
+	noErr(t, err)
+	assertEqual(t, "new", string(got), "subdir1/new.txt")
+
+	noErr(t, fsys.WriteFile("README.md", []byte("replaced"), 0o644))
+	got, err = fs.ReadFile(fsys, "README.md")
+	noErr(t, err)
+	assertEqual(t, "replaced", string(got), "README.md")
+
+	err = fsys.WriteFile("no-dir/new.txt", nil, 0o644)
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatal("expected fs.ErrNotExist, got:", err)
+	}
+	err = fsys.WriteFile("subdir1", nil, 0o644)
+	if !errors.Is(err, syscall.EISDIR) {
+		t.Fatal("expected syscall.EISDIR, got:", err)
+	}
+}
+
+func TestFS_Create(t *testing.T) {
+	fsys, srv := initFakeFileSystem(t, onedrivefstest.Options{})
+
+	f, err := fsys.Create("README.md")
+	noErr(t, err)
+	_, err = f.Write([]byte("created"))
+	noErr(t, err)
+	info, err := f.Stat()
+	noErr(t, err)
+	assertEqual(t, "README.md", info.Name(), "README.md")
+	assertEqual(t, int64(len("created")), info.Size(), "README.md")
+	assertEqual(t, fs.FileMode(0o666), info.Mode(), "README.md")
+	_, err = f.Read(make([]byte, 1))
+	if !errors.Is(err, errors.ErrUnsupported) {
+		t.Fatal("expected errors.ErrUnsupported, got:", err)
+	}
+	// The content is uploaded on Close.
+	got, err := srv.ReadFile("README.md")
+	noErr(t, err)
+	assertEqual(t, "This is a test dir for onedrivefs", string(got), "README.md")
+	noErr(t, f.Close())
+	got, err = srv.ReadFile("README.md")
+	noErr(t, err)
+	assertEqual(t, "created", string(got), "README.md")
+
+	_, err = f.Write([]byte("more"))
+	if !errors.Is(err, fs.ErrClosed) {
+		t.Fatal("expected fs.ErrClosed, got:", err)
+	}
+	if err := f.Close(); !errors.Is(err, fs.ErrClosed) {
+		t.Fatal("expected fs.ErrClosed, got:", err)
+	}
+
+	_, err = fsys.Create("no-dir/new.txt")
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatal("expected fs.ErrNotExist, got:", err)
+	}
+	_, err = fsys.Create("README.md/new.txt")
+	if !errors.Is(err, syscall.ENOTDIR) {
+		t.Fatal("expected syscall.ENOTDIR, got:", err)
+	}
+}
+
+func TestFS_OpenFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		flag    int
+		wantErr error
+	}{
+		{name: "exclusive new file", path: "new.txt", flag: os.O_WRONLY | os.O_CREATE | os.O_EXCL},
+		{name: "exclusive existing file", path: "README.md", flag: os.O_WRONLY | os.O_CREATE | os.O_EXCL, wantErr: fs.ErrExist},
+		{name: "existing file without create", path: "README.md", flag: os.O_WRONLY | os.O_TRUNC},
+		{name: "new file without create", path: "new.txt", flag: os.O_WRONLY, wantErr: fs.ErrNotExist},
+		{name: "existing file without truncate", path: "README.md", flag: os.O_WRONLY, wantErr: errors.ErrUnsupported},
+		{name: "existing file with create without truncate", path: "README.md", flag: os.O_RDWR | os.O_CREATE, wantErr: errors.ErrUnsupported},
+		{name: "append", path: "README.md", flag: os.O_WRONLY | os.O_APPEND, wantErr: errors.ErrUnsupported},
+		{name: "root", path: ".", flag: os.O_WRONLY, wantErr: fs.ErrInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys, srv := initFakeFileSystem(t, onedrivefstest.Options{})
+			f, err := fsys.OpenFile(tt.path, tt.flag, 0o644)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("expected %v, got: %v", tt.wantErr, err)
+				}
+				var pathErr *fs.PathError
+				if !errors.As(err, &pathErr) {
+					t.Fatalf("expected *fs.PathError, got: %T", err)
+				}
+				// A refused open must leave an existing file intact.
+				if tt.path == "README.md" {
+					got, err := srv.ReadFile(tt.path)
+					noErr(t, err)
+					assertEqual(t, "This is a test dir for onedrivefs", string(got), tt.path)
+				}
+				return
+			}
+			noErr(t, err)
+			_, err = f.(WritableFile).Write([]byte("content"))
+			noErr(t, err)
+			noErr(t, f.Close())
+			got, err := fs.ReadFile(fsys, tt.path)
+			noErr(t, err)
+			assertEqual(t, "content", string(got), tt.path)
+		})
+	}
+}
+
+func TestFS_Mkdir(t *testing.T) {
+	fsys, _ := initFakeFileSystem(t, onedrivefstest.Options{})
+
+	noErr(t, fsys.Mkdir("subdir1/new", 0o755))
+	info, err := fsys.Stat("subdir1/new")
+	noErr(t, err)
+	assertEqual(t, true, info.IsDir(), "subdir1/new")
+
+	err = fsys.Mkdir("subdir1/new", 0o755)
+	if !errors.Is(err, fs.ErrExist) {
+		t.Fatal("expected fs.ErrExist, got:", err)
+	}
+	err = fsys.Mkdir("a/b", 0o755)
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatal("expected fs.ErrNotExist, got:", err)
+	}
+
+	noErr(t, fsys.MkdirAll("a/b/c", 0o755))
+	noErr(t, fsys.MkdirAll("a/b/c", 0o755))
+	info, err = fsys.Stat("a/b/c")
+	noErr(t, err)
+	assertEqual(t, true, info.IsDir(), "a/b/c")
+	err = fsys.MkdirAll("README.md/a", 0o755)
+	if !errors.Is(err, syscall.ENOTDIR) {
+		t.Fatal("expected syscall.ENOTDIR, got:", err)
+	}
+}
+
+func TestFS_Remove(t *testing.T) {
+	fsys, _ := initFakeFileSystem(t, onedrivefstest.Options{})
+
+	noErr(t, fsys.Remove("README.md"))
+	_, err := fsys.Stat("README.md")
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatal("expected fs.ErrNotExist, got:", err)
+	}
+	err = fsys.Remove("README.md")
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatal("expected fs.ErrNotExist, got:", err)
+	}
+	err = fsys.Remove("subdir1")
+	if !errors.Is(err, syscall.ENOTEMPTY) {
+		t.Fatal("expected syscall.ENOTEMPTY, got:", err)
+	}
+
+	noErr(t, fsys.RemoveAll("subdir1"))
+	noErr(t, fsys.RemoveAll("subdir1"))
+	_, err = fsys.Stat("subdir1/subdir2/foo.json")
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatal("expected fs.ErrNotExist, got:", err)
+	}
+}
+
+func TestFS_Rename(t *testing.T) {
+	fsys, _ := initFakeFileSystem(t, onedrivefstest.Options{})
+
+	noErr(t, fsys.Rename("subdir1/subdir2/foo.json", "foo.json"))
+	got, err := fs.ReadFile(fsys, "foo.json")
+	noErr(t, err)
+	assertEqual(t, `"is JSON"`, string(got), "foo.json")
+
+	noErr(t, fsys.Rename("foo.json", "README.md"))
+	got, err = fs.ReadFile(fsys, "README.md")
+	noErr(t, err)
+	assertEqual(t, `"is JSON"`, string(got), "README.md")
+
+	noErr(t, fsys.Rename("subdir1/subdir2", "subdir3"))
+	_, err = fsys.Stat("subdir3/foo-json")
+	noErr(t, err)
+
+	err = fsys.Rename("README.md", "subdir1")
+	if !errors.Is(err, fs.ErrExist) {
+		t.Fatal("expected fs.ErrExist, got:", err)
+	}
+	err = fsys.Rename("missing", "other")
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatal("expected fs.ErrNotExist, got:", err)
+	}
+}